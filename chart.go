@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// 图表保留的采样点数，每秒一个点
+const chartPoints = 120
+
+// 图表曲线颜色
+var (
+	colorBlue   = color.NRGBA{R: 0x1e, G: 0x88, B: 0xe5, A: 0xff}
+	colorGray   = color.NRGBA{R: 0x9e, G: 0x9e, B: 0x9e, A: 0xff}
+	colorGreen  = color.NRGBA{R: 0x43, G: 0xa0, B: 0x47, A: 0xff}
+	colorOrange = color.NRGBA{R: 0xfb, G: 0x8c, B: 0x00, A: 0xff}
+	colorRed    = color.NRGBA{R: 0xe5, G: 0x39, B: 0x35, A: 0xff}
+	colorPurple = color.NRGBA{R: 0x8e, G: 0x24, B: 0xaa, A: 0xff}
+)
+
+// 图表中的一条曲线
+type chartSeries struct {
+	name   string
+	color  color.Color
+	points []float64
+}
+
+// lineChart 简单的折线图，横轴为最近 chartPoints 个采样，纵轴从0到最大值自动缩放
+type lineChart struct {
+	widget.BaseWidget
+	title string
+
+	mu     sync.Mutex
+	series []*chartSeries
+}
+
+func newLineChart(title string, names []string, colors []color.Color) *lineChart {
+	c := &lineChart{title: title}
+	for i, name := range names {
+		c.series = append(c.series, &chartSeries{name: name, color: colors[i]})
+	}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// 追加一个采样点，values 与曲线一一对应
+func (c *lineChart) push(values ...float64) {
+	c.mu.Lock()
+	for i, s := range c.series {
+		s.points = append(s.points, values[i])
+		if len(s.points) > chartPoints {
+			s.points = s.points[len(s.points)-chartPoints:]
+		}
+	}
+	c.mu.Unlock()
+	c.Refresh()
+}
+
+// 清空所有曲线，开始新的执行时调用
+func (c *lineChart) reset() {
+	c.mu.Lock()
+	for _, s := range c.series {
+		s.points = nil
+	}
+	c.mu.Unlock()
+	c.Refresh()
+}
+
+func (c *lineChart) MinSize() fyne.Size {
+	return fyne.NewSize(200, 140)
+}
+
+func (c *lineChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &lineChartRenderer{
+		chart:      c,
+		background: canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground)),
+		title:      canvas.NewText(c.title, theme.Color(theme.ColorNameForeground)),
+		maxLabel:   canvas.NewText("", theme.Color(theme.ColorNamePlaceHolder)),
+	}
+	r.title.TextStyle.Bold = true
+	r.title.TextSize = theme.CaptionTextSize()
+	r.maxLabel.TextSize = theme.CaptionTextSize()
+	for _, s := range c.series {
+		legend := canvas.NewText("■ "+s.name, s.color)
+		legend.TextSize = theme.CaptionTextSize()
+		r.legends = append(r.legends, legend)
+	}
+	return r
+}
+
+type lineChartRenderer struct {
+	chart      *lineChart
+	background *canvas.Rectangle
+	title      *canvas.Text
+	maxLabel   *canvas.Text
+	legends    []*canvas.Text
+	lines      []fyne.CanvasObject
+	size       fyne.Size
+}
+
+func (r *lineChartRenderer) Layout(size fyne.Size) {
+	r.size = size
+	r.background.Resize(size)
+	r.title.Move(fyne.NewPos(4, 2))
+
+	x := r.title.MinSize().Width + 16
+	for _, legend := range r.legends {
+		legend.Move(fyne.NewPos(x, 2))
+		x += legend.MinSize().Width + 10
+	}
+	r.buildLines()
+}
+
+// 按当前尺寸和数据重建折线
+func (r *lineChartRenderer) buildLines() {
+	r.lines = r.lines[:0]
+	header := r.title.MinSize().Height + 4
+	plotHeight := r.size.Height - header - 4
+	plotWidth := r.size.Width - 8
+	if plotHeight <= 0 || plotWidth <= 0 {
+		return
+	}
+
+	r.chart.mu.Lock()
+	defer r.chart.mu.Unlock()
+
+	max := 0.0
+	for _, s := range r.chart.series {
+		for _, v := range s.points {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	if max <= 0 {
+		max = 1
+	}
+	max *= 1.1
+	r.maxLabel.Text = fmt.Sprintf("max %.1f", max)
+	r.maxLabel.Move(fyne.NewPos(r.size.Width-r.maxLabel.MinSize().Width-4, 2))
+
+	step := plotWidth / float32(chartPoints-1)
+	point := func(i int, v float64) fyne.Position {
+		return fyne.NewPos(4+float32(i)*step, header+plotHeight*(1-float32(v/max)))
+	}
+	for _, s := range r.chart.series {
+		// 数据不足 chartPoints 个时靠右对齐，最新的点总在最右侧
+		offset := chartPoints - len(s.points)
+		for i := 1; i < len(s.points); i++ {
+			line := canvas.NewLine(s.color)
+			line.StrokeWidth = 1.5
+			line.Position1 = point(offset+i-1, s.points[i-1])
+			line.Position2 = point(offset+i, s.points[i])
+			r.lines = append(r.lines, line)
+		}
+	}
+}
+
+func (r *lineChartRenderer) MinSize() fyne.Size {
+	return r.chart.MinSize()
+}
+
+func (r *lineChartRenderer) Refresh() {
+	r.buildLines()
+	canvas.Refresh(r.chart)
+}
+
+func (r *lineChartRenderer) Objects() []fyne.CanvasObject {
+	objects := []fyne.CanvasObject{r.background, r.title, r.maxLabel}
+	for _, legend := range r.legends {
+		objects = append(objects, legend)
+	}
+	return append(objects, r.lines...)
+}
+
+func (r *lineChartRenderer) Destroy() {}
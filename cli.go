@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"http-gui-tool/engine"
+)
+
+// 命令行模式退出码
+const (
+	exitOK     = 0 // 全部行执行成功
+	exitFailed = 1 // 存在失败行或执行被中断
+	exitUsage  = 2 // 参数或配置错误
+)
+
+const cliUsage = `用法:
+  http-gui-tool                                      启动图形界面
+  http-gui-tool run --config job.json --csv data.csv [--env prod] [--sheet 订单]
+                    [--encoding gbk] [--result results.csv] [--checkpoint job.checkpoint [--resume]]
+                    [--report report.json]
+                    [--diff diff.csv] [--vault secrets.vault]
+                                                     无界面执行批量请求
+  http-gui-tool preview --config job.json --csv data.csv [--env prod] [--rows 10 | --rows 20-30]
+                        [--vault secrets.vault] [--sheet 订单] [--encoding gbk]
+                                                     预览生成的请求，不发送
+
+--csv 也可以是 JSONL（.jsonl、.ndjson）或 Excel（.xlsx）文件，按扩展名识别，其他扩展名使用配置中的
+inputFormat；Excel 文件用 --sheet 指定工作表，--result 为 .xlsx 时把结果写回工作簿的副本。
+CSV / JSONL 文件的编码默认自动检测，可用 --encoding 指定: utf-8、utf-8-bom、gbk、gb18030、utf-16。
+配置中引用了 ${secret:名称} 时需要用 --vault 指定保险库文件，密码通过环境变量
+HTTP_TOOL_VAULT_PASSPHRASE 提供
+`
+
+// 命令行模式下读取保险库密码的环境变量
+const vaultPassphraseEnv = "HTTP_TOOL_VAULT_PASSPHRASE"
+
+// runCLI 无界面执行保存的配置，返回进程退出码
+func runCLI(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, cliUsage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "配置文件路径（图形界面保存的JSON）")
+	csvPath := fs.String("csv", "", "数据文件路径（CSV、JSONL 或 Excel）")
+	sheet := fs.String("sheet", "", "Excel 数据文件的工作表，覆盖配置中的 sheet")
+	encoding := fs.String("encoding", "", "数据文件的字符编码（auto、utf-8、utf-8-bom、gbk、gb18030、utf-16），覆盖配置中的 encoding")
+	env := fs.String("env", "", "使用的环境，覆盖配置中的 environment")
+	vaultPath := fs.String("vault", "", "保险库文件路径，配置引用了 ${secret:名称} 时需要")
+	checkpointPath := fs.String("checkpoint", "", "断点文件路径，记录已成功的行")
+	resume := fs.Bool("resume", false, "从断点文件继续执行，跳过已成功的行（需配合 --checkpoint）")
+	resultPath := fs.String("result", "", "逐行结果输出文件（.csv 或 .jsonl），覆盖配置中的 resultFile")
+	reportPath := fs.String("report", "", "执行结束后导出统计报告（.json 为JSON格式，其余为文本）")
+	diffPath := fs.String("diff", "", "对比模式下导出响应不一致的行（.jsonl 含各目标的完整响应，其余为CSV）")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *configPath == "" || *csvPath == "" || (*resume && *checkpointPath == "") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	config, err := readConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *env != "" {
+		config.Environment = *env
+	}
+	applyInputFormat(config, *csvPath, *sheet, *encoding)
+	if err := loadSecrets(config, *vaultPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *resultPath != "" {
+		config.ResultFile = *resultPath
+		config.ResultFormat = engine.ResultFormatFromPath(*resultPath)
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+		return exitUsage
+	}
+
+	header, err := engine.ReadHeader(*csvPath, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取数据文件标题行失败: %v\n", err)
+		return exitUsage
+	}
+	if err := engine.ValidateInput(config, header); err != nil {
+		fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+		return exitUsage
+	}
+
+	// Ctrl+C / SIGTERM 时停止派发并等待进行中的请求结束
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	src, err := engine.OpenSource(*csvPath, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	defer src.Close()
+
+	runner := engine.NewRunner(config, handleCLIEvent)
+	if *checkpointPath != "" {
+		checkpoint, err := engine.OpenCheckpoint(*checkpointPath, *csvPath, *resume)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		defer checkpoint.Close()
+		runner.SetCheckpoint(checkpoint)
+	}
+	summary, err := runner.Run(ctx, src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var configErr *engine.ConfigError
+		if errors.As(err, &configErr) {
+			return exitUsage
+		}
+		return exitFailed
+	}
+
+	status := "completed"
+	if summary.Stopped {
+		status = "interrupted"
+	}
+	cliLog(fmt.Sprintf("Execution %s - Total: %s%d, %s, Not run: %s%d",
+		status, approx(summary.Estimated), summary.Total, summary.Counts, approx(summary.Estimated), summary.Total-summary.Done()))
+	fmt.Print(summary.Report.Text())
+	if *reportPath != "" {
+		if err := engine.WriteReport(*reportPath, summary.Report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			cliLog(fmt.Sprintf("Report written to %s", *reportPath))
+		}
+	}
+	if summary.Mismatch > 0 {
+		cliLog(fmt.Sprintf("%d rows differ across targets", summary.Mismatch))
+		if *diffPath != "" {
+			if err := engine.WriteMismatches(*diffPath, summary.Mismatches); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				cliLog(fmt.Sprintf("Diff of %d rows written to %s", len(summary.Mismatches), *diffPath))
+			}
+		}
+	}
+	if summary.Stopped {
+		return exitFailed
+	}
+	if summary.Failed() > 0 || summary.Cancelled > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// runPreview 输出指定数据行将要发送的请求，不进行网络请求。
+// 存在参数映射或模板渲染错误的行时返回 exitFailed
+func runPreview(args []string) int {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, cliUsage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "配置文件路径（图形界面保存的JSON）")
+	csvPath := fs.String("csv", "", "数据文件路径（CSV、JSONL 或 Excel）")
+	sheet := fs.String("sheet", "", "Excel 数据文件的工作表，覆盖配置中的 sheet")
+	encoding := fs.String("encoding", "", "数据文件的字符编码（auto、utf-8、utf-8-bom、gbk、gb18030、utf-16），覆盖配置中的 encoding")
+	env := fs.String("env", "", "使用的环境，覆盖配置中的 environment")
+	vaultPath := fs.String("vault", "", "保险库文件路径，配置引用了 ${secret:名称} 时需要")
+	rows := fs.String("rows", "10", "预览的数据行：N 表示前N行，M-N 表示第M到N行（从1开始，不含标题行）")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *configPath == "" || *csvPath == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	from, to, err := engine.ParseRowRange(*rows)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	config, err := readConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *env != "" {
+		config.Environment = *env
+	}
+	applyInputFormat(config, *csvPath, *sheet, *encoding)
+	if err := loadSecrets(config, *vaultPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	src, err := engine.OpenSource(*csvPath, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	defer src.Close()
+
+	previews, err := engine.Preview(config, src, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "配置错误: %v\n", err)
+		return exitUsage
+	}
+	problems := 0
+	for _, p := range previews {
+		fmt.Printf("### Row %d -> %s\n", p.RowIndex, p.Target)
+		for _, mappingErr := range p.MappingErrors {
+			fmt.Printf("!!! Param mapping error %s\n", mappingErr)
+		}
+		if p.Error != "" {
+			fmt.Printf("!!! %s\n\n", p.Error)
+		} else {
+			fmt.Println(p.Text())
+		}
+		if p.HasProblem() {
+			problems++
+		}
+	}
+	fmt.Printf("Previewed %d rows, %d with errors\n", len(previews), problems)
+	if problems > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// 读取并解析配置文件
+func readConfigFile(path string) (*engine.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Config file read failed: %v", err)
+	}
+	var config engine.Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Config file parse failed: %v", err)
+	}
+	return &config, nil
+}
+
+// 数据文件的扩展名能识别格式时以扩展名为准，否则使用配置中的格式；命令行指定的工作表和编码覆盖配置
+func applyInputFormat(config *engine.Config, path, sheet, encoding string) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".jsonl", ".ndjson", ".xlsx":
+		config.InputFormat = engine.InputFormatFromPath(path)
+	}
+	if sheet != "" {
+		config.Sheet = sheet
+	}
+	if encoding != "" {
+		config.Encoding = encoding
+	}
+}
+
+// 配置引用了密钥时解锁保险库，密码从环境变量读取
+func loadSecrets(config *engine.Config, vaultPath string) error {
+	if !config.UsesSecrets() {
+		return nil
+	}
+	if vaultPath == "" {
+		return fmt.Errorf("配置引用了保险库中的密钥，需要用 --vault 指定保险库文件")
+	}
+	if !engine.VaultExists(vaultPath) {
+		return fmt.Errorf("保险库文件不存在: %s", vaultPath)
+	}
+	passphrase := os.Getenv(vaultPassphraseEnv)
+	if passphrase == "" {
+		return fmt.Errorf("需要通过环境变量 %s 提供保险库密码", vaultPassphraseEnv)
+	}
+	vault, err := engine.OpenVault(vaultPath, passphrase)
+	if err != nil {
+		return err
+	}
+	config.Secrets = vault.Secrets()
+	return nil
+}
+
+// 总行数为估算值时加上 ~
+func approx(estimated bool) string {
+	if estimated {
+		return "~"
+	}
+	return ""
+}
+
+// 命令行模式下把执行事件输出到标准输出
+func handleCLIEvent(event engine.Event) {
+	switch event.Type {
+	case engine.EventProgress:
+		p := event.Progress
+		cliLog(fmt.Sprintf("Progress %d/%s%d (%.0f%%) - Target QPS: %.1f, %s, throughput %.1f/s, error rate %.2f%%, latency %s",
+			p.Processed, approx(p.Estimated), p.Total, float64(p.Processed)*100/float64(p.Total), p.TargetQPS, p.Counts,
+			p.Stats.Throughput, p.Stats.ErrorRate*100, p.Stats.Latency))
+	case engine.EventLog, engine.EventRowSucceeded, engine.EventRowFailed:
+		cliLog(event.Message)
+	}
+}
+
+// 命令行日志直接输出到标准输出，格式与界面日志一致
+func cliLog(message string) {
+	fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), message)
+}
@@ -0,0 +1,406 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 断言类型
+const (
+	AssertStatus   = "status"   // HTTP状态码
+	AssertBody     = "body"     // 响应体文本
+	AssertJSONPath = "jsonpath" // 响应体JSON中的字段，Field 为路径，如 $.data.code
+	AssertHeader   = "header"   // 响应头，Field 为名称
+	AssertLatency  = "latency"  // 单次请求耗时（毫秒）
+)
+
+// 断言结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFail    = "fail"
+	OutcomeRetry   = "retry"
+)
+
+// AssertTypes 支持的断言类型
+var AssertTypes = []string{AssertStatus, AssertBody, AssertJSONPath, AssertHeader, AssertLatency}
+
+// AssertOps 支持的比较方式，! 开头的为取反
+var AssertOps = []string{
+	"in", "!in", "==", "!=", ">", ">=", "<", "<=",
+	"contains", "!contains", "matches", "!matches", "exists", "!exists",
+}
+
+// Outcomes 断言命中后的结果
+var Outcomes = []string{OutcomeSuccess, OutcomeFail, OutcomeRetry}
+
+// Assertion 响应断言规则。规则按顺序匹配，第一条命中的规则决定该次请求的结果
+type Assertion struct {
+	Name    string `json:"name,omitempty"`  // 规则说明，用于日志和结果文件
+	Type    string `json:"type"`            // status, body, jsonpath, header, latency
+	Field   string `json:"field,omitempty"` // jsonpath 的路径或 header 的名称
+	Op      string `json:"op"`              // 比较方式，见 AssertOps
+	Value   string `json:"value,omitempty"` // 比较值：in 为状态码范围（如 500-599,404），matches 为正则
+	Outcome string `json:"outcome"`         // success, fail 或 retry
+}
+
+// DefaultAssertions 未配置断言时使用的规则，与原先的判断逻辑一致：
+// 5xx 重试，4xx 失败，响应体包含 call failed 时重试，其余成功
+func DefaultAssertions() []Assertion {
+	return []Assertion{
+		{Name: "server error", Type: AssertStatus, Op: "in", Value: "500-599", Outcome: OutcomeRetry},
+		{Name: "client error", Type: AssertStatus, Op: "in", Value: "400-499", Outcome: OutcomeFail},
+		{Name: "call failed", Type: AssertBody, Op: "contains", Value: "call failed", Outcome: OutcomeRetry},
+	}
+}
+
+// 断言的说明，未填写名称时由规则内容生成
+func (a Assertion) String() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	subject := a.Type
+	if a.Field != "" {
+		subject += " " + a.Field
+	}
+	if a.Op == "exists" || a.Op == "!exists" {
+		return subject + " " + a.Op
+	}
+	return fmt.Sprintf("%s %s %s", subject, a.Op, a.Value)
+}
+
+// statusRange 闭区间状态码范围
+type statusRange struct {
+	min, max int
+}
+
+// compiledAssertion 编译后的断言，正则、路径和状态码范围只解析一次
+type compiledAssertion struct {
+	Assertion
+	negate bool
+	op     string // 去掉 ! 之后的比较方式
+	regex  *regexp.Regexp
+	path   []interface{} // JSON路径，元素为 string（键）或 int（下标）
+	ranges []statusRange
+}
+
+// response 断言时使用的单次响应
+type response struct {
+	status  int
+	header  http.Header
+	body    []byte
+	latency time.Duration
+
+	decoded interface{} // 按需解析的响应体JSON
+	parsed  bool
+	valid   bool
+}
+
+func (r *response) json() (interface{}, bool) {
+	if !r.parsed {
+		r.parsed = true
+		decoder := json.NewDecoder(bytes.NewReader(r.body))
+		decoder.UseNumber()
+		r.valid = decoder.Decode(&r.decoded) == nil
+	}
+	return r.decoded, r.valid
+}
+
+// 编译断言规则，规则有误时返回错误
+func compileAssertions(assertions []Assertion) ([]compiledAssertion, error) {
+	compiled := make([]compiledAssertion, 0, len(assertions))
+	for i, assertion := range assertions {
+		c, err := compileAssertion(assertion)
+		if err != nil {
+			return nil, fmt.Errorf("第%d条断言 [%s] 无效: %v", i+1, assertion, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compileAssertion(a Assertion) (compiledAssertion, error) {
+	c := compiledAssertion{Assertion: a, op: strings.TrimPrefix(a.Op, "!")}
+	c.negate = c.op != a.Op
+	if a.Op == "!=" {
+		c.op = "=="
+	}
+
+	if !contains(AssertTypes, a.Type) {
+		return c, fmt.Errorf("不支持的断言类型 %q", a.Type)
+	}
+	if !contains(AssertOps, a.Op) {
+		return c, fmt.Errorf("不支持的比较方式 %q", a.Op)
+	}
+	if !contains(Outcomes, a.Outcome) {
+		return c, fmt.Errorf("不支持的断言结果 %q", a.Outcome)
+	}
+	if (a.Type == AssertJSONPath || a.Type == AssertHeader) && strings.TrimSpace(a.Field) == "" {
+		return c, fmt.Errorf("%s 断言需要填写字段", a.Type)
+	}
+
+	var err error
+	switch c.op {
+	case "matches":
+		c.regex, err = regexp.Compile(a.Value)
+	case "in":
+		c.ranges, err = parseStatusRanges(a.Value)
+	case ">", ">=", "<", "<=":
+		_, err = strconv.ParseFloat(strings.TrimSpace(a.Value), 64)
+	}
+	if err != nil {
+		return c, err
+	}
+	if a.Type == AssertJSONPath {
+		c.path, err = parseJSONPath(a.Field)
+	}
+	return c, err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析状态码范围列表，如 "200,201,204-206" 或 "5xx"
+func parseStatusRanges(value string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if len(part) == 3 && strings.HasSuffix(strings.ToLower(part), "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil {
+				return nil, fmt.Errorf("状态码范围格式错误: %s", part)
+			}
+			ranges = append(ranges, statusRange{class * 100, class*100 + 99})
+			continue
+		}
+		low, high, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return nil, fmt.Errorf("状态码范围格式错误: %s", part)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.Atoi(strings.TrimSpace(high)); err != nil || max < min {
+				return nil, fmt.Errorf("状态码范围格式错误: %s", part)
+			}
+		}
+		ranges = append(ranges, statusRange{min, max})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("状态码范围不能为空")
+	}
+	return ranges, nil
+}
+
+// 解析JSON路径，支持 $.a.b、$.list[0]、$['key'] 写法，省略开头的 $ 时视为从根开始
+func parseJSONPath(path string) ([]interface{}, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	if path != "" && path[0] != '.' && path[0] != '[' {
+		path = "." + path
+	}
+
+	var segments []interface{}
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSON路径格式错误")
+			}
+			segments = append(segments, path[:end])
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSON路径缺少 ]")
+			}
+			inner := strings.TrimSpace(path[1:end])
+			path = path[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("JSON路径下标格式错误: %s", inner)
+			}
+			segments = append(segments, index)
+		default:
+			return nil, fmt.Errorf("JSON路径格式错误")
+		}
+	}
+	return segments, nil
+}
+
+// 按路径取JSON中的值，转为字符串后参与比较
+func lookupJSONPath(root interface{}, path []interface{}) (string, bool) {
+	value, ok := lookupJSONValue(root, path)
+	if !ok {
+		return "", false
+	}
+	return jsonText(value), true
+}
+
+// 按路径取出JSON值，found 为 false 表示字段不存在
+func lookupJSONValue(root interface{}, path []interface{}) (interface{}, bool) {
+	value := root
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			if key < 0 {
+				key += len(array)
+			}
+			if key < 0 || key >= len(array) {
+				return nil, false
+			}
+			value = array[key]
+		}
+	}
+	return value, true
+}
+
+// JSON值的文本形式：字符串不带引号，对象和数组为紧凑的JSON
+func jsonText(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// 取出断言要比较的实际值，found 为 false 表示字段不存在
+func (c *compiledAssertion) actual(resp *response) (value string, found bool) {
+	switch c.Type {
+	case AssertStatus:
+		return strconv.Itoa(resp.status), true
+	case AssertBody:
+		return string(resp.body), true
+	case AssertJSONPath:
+		root, ok := resp.json()
+		if !ok {
+			return "", false
+		}
+		return lookupJSONPath(root, c.path)
+	case AssertHeader:
+		values := resp.header.Values(c.Field)
+		return strings.Join(values, ", "), len(values) > 0
+	case AssertLatency:
+		return strconv.FormatFloat(durationMs(resp.latency), 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// 判断规则是否命中。字段不存在时肯定形式的比较不命中，取反形式的比较命中
+func (c *compiledAssertion) match(resp *response) (bool, string) {
+	actual, found := c.actual(resp)
+	matched := found && c.compare(actual)
+	if c.negate {
+		matched = !matched
+	}
+	return matched, actual
+}
+
+func (c *compiledAssertion) compare(actual string) bool {
+	expected := c.Value
+	switch c.op {
+	case "exists":
+		return true
+	case "contains":
+		return strings.Contains(actual, expected)
+	case "matches":
+		return c.regex.MatchString(actual)
+	case "in":
+		status, err := strconv.Atoi(strings.TrimSpace(actual))
+		if err != nil {
+			return false
+		}
+		for _, r := range c.ranges {
+			if status >= r.min && status <= r.max {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 两边都是数字时按数值比较，否则按字符串比较
+	a, errA := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+	if errA != nil || errB != nil {
+		switch c.op {
+		case "==":
+			return actual == expected
+		default:
+			return false
+		}
+	}
+	switch c.op {
+	case "==":
+		return a == b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// 按顺序执行断言，返回第一条命中规则的结果和原因；没有规则命中时使用 fallback
+func evaluateAssertions(assertions []compiledAssertion, fallback string, resp *response) (string, string) {
+	for i := range assertions {
+		assertion := &assertions[i]
+		if matched, actual := assertion.match(resp); matched {
+			if len(actual) > 200 {
+				actual = actual[:200] + "..."
+			}
+			subject := assertion.Type
+			if assertion.Field != "" {
+				subject = assertion.Field
+			}
+			return assertion.Outcome, fmt.Sprintf("%s (%s=%s)", assertion, subject, actual)
+		}
+	}
+	return fallback, "no assertion matched"
+}
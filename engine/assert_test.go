@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEvaluateAssertions(t *testing.T) {
+	rules := []Assertion{
+		{Type: AssertJSONPath, Field: "$.data.items[-1].code", Op: "==", Value: "E1", Outcome: OutcomeFail},
+		{Type: AssertJSONPath, Field: "$['data'].ok", Op: "==", Value: "false", Outcome: OutcomeRetry},
+		{Type: AssertHeader, Field: "X-Busy", Op: "exists", Outcome: OutcomeRetry},
+		{Type: AssertLatency, Op: ">", Value: "1000", Outcome: OutcomeRetry},
+		{Type: AssertBody, Op: "matches", Value: `^err:\d+`, Outcome: OutcomeFail},
+	}
+	rules = append(rules, DefaultAssertions()...)
+	compiled, err := compileAssertions(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		body    string
+		latency time.Duration
+		want    string
+	}{
+		{"plain 200", 200, nil, `{"data":{"ok":true}}`, 0, OutcomeSuccess},
+		{"last item code", 200, nil, `{"data":{"items":[{"code":"E0"},{"code":"E1"}]}}`, 0, OutcomeFail},
+		{"json false", 200, nil, `{"data":{"ok":false}}`, 0, OutcomeRetry},
+		{"header exists", 200, http.Header{"X-Busy": {"1"}}, "", 0, OutcomeRetry},
+		{"slow", 200, nil, "", 2 * time.Second, OutcomeRetry},
+		{"body regex", 200, nil, "err:42", 0, OutcomeFail},
+		{"server error", 503, nil, "", 0, OutcomeRetry},
+		{"client error", 404, nil, "", 0, OutcomeFail},
+		{"call failed", 200, nil, "remote call failed", 0, OutcomeRetry},
+		{"not json", 200, nil, "<html>", 0, OutcomeSuccess},
+	}
+	for _, tt := range tests {
+		got, reason := evaluateAssertions(compiled, OutcomeSuccess, &response{
+			status: tt.status, header: tt.header, body: []byte(tt.body), latency: tt.latency,
+		})
+		if got != tt.want {
+			t.Errorf("%s: outcome = %s (%s), want %s", tt.name, got, reason, tt.want)
+		}
+	}
+}
+
+func TestCompileAssertionErrors(t *testing.T) {
+	for _, a := range []Assertion{
+		{Type: AssertStatus, Op: "in", Value: "5x", Outcome: OutcomeRetry},
+		{Type: AssertStatus, Op: "in", Value: "500-400", Outcome: OutcomeRetry},
+		{Type: AssertBody, Op: "matches", Value: "(", Outcome: OutcomeFail},
+		{Type: AssertJSONPath, Field: "$.a[x]", Op: "exists", Outcome: OutcomeFail},
+		{Type: AssertLatency, Op: ">", Value: "slow", Outcome: OutcomeRetry},
+		{Type: "cookie", Op: "exists", Outcome: OutcomeFail},
+	} {
+		if _, err := compileAssertions([]Assertion{a}); err == nil {
+			t.Errorf("compileAssertions(%+v) accepted", a)
+		}
+	}
+}
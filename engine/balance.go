@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	BalanceRoundRobin       = "round-robin"       // 按请求依次轮询
+	BalanceWeighted         = "weighted"          // 按权重平滑轮询
+	BalanceLeastOutstanding = "least-outstanding" // 选择在途请求最少的目标（按权重折算）
+	BalanceRandom           = "random"            // 按权重随机
+	BalanceHash             = "hash"              // 按指定列的值一致性哈希，同一个值总是发往同一个目标
+)
+
+// Balances 支持的负载均衡策略
+var Balances = []string{BalanceRoundRobin, BalanceWeighted, BalanceLeastOutstanding, BalanceRandom, BalanceHash}
+
+// 一致性哈希中每单位权重的虚拟节点数
+const hashReplicas = 100
+
+// target IP列表中的一个目标及其运行状态，字段由 targetPool 加锁访问
+type target struct {
+	addr        string
+	weight      int
+	outstanding int // 在途请求数
+
+	// 熔断状态
+	state    breakerState
+	failures int       // 连续失败次数
+	retryAt  time.Time // 熔断后允许探测的时间
+	probing  bool      // 半开状态下探测请求是否在途
+}
+
+// 解析IP列表，每行为 "ip:port" 或 "ip:port weight=3"，权重默认为1
+func parseTargets(ipList []string) ([]*target, error) {
+	var targets []*target
+	seen := make(map[string]bool)
+	for _, line := range cleanIPList(ipList) {
+		fields := strings.Fields(line)
+		t := &target{addr: fields[0], weight: 1}
+		if seen[t.addr] {
+			return nil, fmt.Errorf("IP地址重复: %s，需要加大比例请使用 weight=N", t.addr)
+		}
+		seen[t.addr] = true
+		for _, option := range fields[1:] {
+			name, value, _ := strings.Cut(option, "=")
+			weight, err := strconv.Atoi(value)
+			if name != "weight" || err != nil || weight <= 0 {
+				return nil, fmt.Errorf("IP地址 %s 的选项格式错误，应为 weight=正整数: %s", t.addr, option)
+			}
+			t.weight = weight
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// 按当前环境展开IP列表后解析目标
+func (c *Config) targets() ([]*target, error) {
+	ips, err := c.IPs()
+	if err != nil {
+		return nil, err
+	}
+	return parseTargets(ips)
+}
+
+// 目标地址列表，忽略权重等选项
+func targetAddrs(ipList []string) []string {
+	var addrs []string
+	for _, line := range cleanIPList(ipList) {
+		addrs = append(addrs, strings.Fields(line)[0])
+	}
+	return addrs
+}
+
+// 一次选择目标的请求
+type pickRequest struct {
+	rowIndex int
+	key      string          // 一致性哈希使用的键
+	tried    map[string]bool // 该行已经请求过的目标，重试时优先选择其他目标
+}
+
+// balancer 负载均衡策略，从可用的目标中选择一个，调用时已持有 targetPool 的锁
+type balancer interface {
+	pick(candidates []*target, req pickRequest) *target
+}
+
+func newBalancer(strategy string, targets []*target) balancer {
+	switch strategy {
+	case BalanceWeighted:
+		return &weightedBalancer{current: make(map[*target]int)}
+	case BalanceLeastOutstanding:
+		return &leastOutstandingBalancer{}
+	case BalanceRandom:
+		return &randomBalancer{rand: rand.New(rand.NewSource(rand.Int63()))}
+	case BalanceHash:
+		return newHashBalancer(targets)
+	default:
+		return &roundRobinBalancer{}
+	}
+}
+
+// 轮询：每次请求（含重试）依次选择下一个目标
+type roundRobinBalancer struct {
+	next int
+}
+
+func (b *roundRobinBalancer) pick(candidates []*target, req pickRequest) *target {
+	t := candidates[b.next%len(candidates)]
+	b.next++
+	return t
+}
+
+// 平滑加权轮询：每次所有候选目标的当前值加上权重，选出最大者后减去权重总和，
+// 权重 3:1 时选择顺序为 A A B A，不会连续集中到同一个目标
+type weightedBalancer struct {
+	current map[*target]int
+}
+
+func (b *weightedBalancer) pick(candidates []*target, req pickRequest) *target {
+	var best *target
+	total := 0
+	for _, t := range candidates {
+		b.current[t] += t.weight
+		total += t.weight
+		if best == nil || b.current[t] > b.current[best] {
+			best = t
+		}
+	}
+	b.current[best] -= total
+	return best
+}
+
+// 最少在途请求：按 在途请求数/权重 选择最空闲的目标，相同时轮流选择
+type leastOutstandingBalancer struct {
+	next int
+}
+
+func (b *leastOutstandingBalancer) pick(candidates []*target, req pickRequest) *target {
+	var best *target
+	start := b.next % len(candidates)
+	b.next++
+	for i := range candidates {
+		t := candidates[(start+i)%len(candidates)]
+		// 比较 outstanding/weight，交叉相乘避免浮点数
+		if best == nil || t.outstanding*best.weight < best.outstanding*t.weight {
+			best = t
+		}
+	}
+	return best
+}
+
+// 按权重随机
+type randomBalancer struct {
+	rand *rand.Rand
+}
+
+func (b *randomBalancer) pick(candidates []*target, req pickRequest) *target {
+	total := 0
+	for _, t := range candidates {
+		total += t.weight
+	}
+	n := b.rand.Intn(total)
+	for _, t := range candidates {
+		if n < t.weight {
+			return t
+		}
+		n -= t.weight
+	}
+	return candidates[len(candidates)-1]
+}
+
+// 一致性哈希：每个目标按权重在哈希环上放置虚拟节点，键落在环上后顺时针找到第一个可用的目标。
+// 目标熔断或重试时顺延到环上的下一个目标，其他键的分配不受影响
+type hashBalancer struct {
+	ring []hashNode
+}
+
+type hashNode struct {
+	hash   uint32
+	target *target
+}
+
+// FNV-1a 后再做一次 murmur3 的混淆，相近的字符串（如 n1、n2）也能均匀分布在环上
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	v := h.Sum32()
+	v ^= v >> 16
+	v *= 0x85ebca6b
+	v ^= v >> 13
+	v *= 0xc2b2ae35
+	v ^= v >> 16
+	return v
+}
+
+func newHashBalancer(targets []*target) *hashBalancer {
+	b := &hashBalancer{}
+	for _, t := range targets {
+		for i := 0; i < t.weight*hashReplicas; i++ {
+			b.ring = append(b.ring, hashNode{hash: hashKey(t.addr + "#" + strconv.Itoa(i)), target: t})
+		}
+	}
+	sort.Slice(b.ring, func(i, k int) bool {
+		return b.ring[i].hash < b.ring[k].hash
+	})
+	return b
+}
+
+func (b *hashBalancer) pick(candidates []*target, req pickRequest) *target {
+	allowed := make(map[*target]bool, len(candidates))
+	for _, t := range candidates {
+		allowed[t] = true
+	}
+	key := req.key
+	if key == "" {
+		// 该行的列值为空时按行号分散，避免都集中到同一个目标
+		key = strconv.Itoa(req.rowIndex)
+	}
+	h := hashKey(key)
+	start := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= h
+	})
+	for i := range b.ring {
+		node := b.ring[(start+i)%len(b.ring)]
+		if allowed[node.target] {
+			return node.target
+		}
+	}
+	return candidates[0]
+}
+
+// 解析一致性哈希使用的列，支持列名或列索引
+func resolveColumn(header []string, column string) (int, error) {
+	column = strings.TrimSpace(column)
+	if index, err := strconv.Atoi(column); err == nil {
+		return index, nil
+	}
+	index, ok := columnIndex(header)[normalizeColumnName(column)]
+	if !ok {
+		return 0, fmt.Errorf("负载均衡引用的列 %q 不存在，可用列: %s", column, strings.Join(splitRow(header), ", "))
+	}
+	return index, nil
+}
+
+// 一致性哈希的键：指定列的值，未配置哈希策略或该行缺少这一列时为空
+func (r *Runner) balanceKey(row []string) string {
+	if r.balancePath != nil {
+		root, err := decodeJSONLine(row)
+		if err != nil {
+			return ""
+		}
+		if value, ok := lookupJSONValue(root, r.balancePath); ok {
+			return strings.TrimSpace(jsonText(value))
+		}
+		return ""
+	}
+	if r.balanceColumn < 0 {
+		return ""
+	}
+	row = splitRow(row)
+	if r.balanceColumn < len(row) {
+		return strings.TrimSpace(row[r.balanceColumn])
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+// 按策略分配若干次，返回各目标被选中的次数
+func pickCounts(t *testing.T, pool *targetPool, n int, key func(i int) string) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		addr, err := pool.acquire(context.Background(), pickRequest{rowIndex: i, key: key(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.report(addr, true)
+		counts[addr]++
+	}
+	return counts
+}
+
+func TestBalancers(t *testing.T) {
+	sameKey := func(int) string { return "order-1" }
+	tests := []struct {
+		strategy string
+		want     map[string]int
+	}{
+		{"", map[string]int{"a:1": 20, "b:1": 20}},
+		{BalanceWeighted, map[string]int{"a:1": 10, "b:1": 30}},
+		{BalanceLeastOutstanding, nil},
+		{BalanceHash, nil},
+	}
+	for _, tt := range tests {
+		counts := pickCounts(t, testPool(t, tt.strategy, 0, "a:1", "b:1 weight=3"), 40, sameKey)
+		switch {
+		case tt.want != nil:
+			if counts["a:1"] != tt.want["a:1"] || counts["b:1"] != tt.want["b:1"] {
+				t.Errorf("%q: counts = %v, want %v", tt.strategy, counts, tt.want)
+			}
+		case tt.strategy == BalanceHash:
+			// 同一个键总是分配到同一个目标
+			if len(counts) != 1 {
+				t.Errorf("hash: counts = %v, want a single target", counts)
+			}
+		default:
+			if counts["a:1"]+counts["b:1"] != 40 {
+				t.Errorf("%q: counts = %v", tt.strategy, counts)
+			}
+		}
+	}
+}
+
+// 重试时优先选择该行还没有请求过的目标
+func TestRetryPrefersUntriedTarget(t *testing.T) {
+	pool := testPool(t, "", 0, "a:1", "b:1")
+	ctx := context.Background()
+	first, _ := pool.acquire(ctx, pickRequest{tried: map[string]bool{}})
+	pool.release(first)
+	second, _ := pool.acquire(ctx, pickRequest{tried: map[string]bool{first: true}})
+	if second == first {
+		t.Errorf("retry picked the same target %s", first)
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	for _, ipList := range [][]string{{"a:1", "a:1"}, {"a:1 weight=0"}, {"a:1 w=2"}} {
+		if _, err := parseTargets(ipList); err == nil {
+			t.Errorf("parseTargets(%q) accepted", ipList)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpointMeta 断点文件首行记录的元信息
+type checkpointMeta struct {
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Checkpoint 记录已成功完成的行号，用于中断后继续执行。
+// 文件首行为元信息，之后每行一个已成功的行号，只追加写入，进程崩溃时最多丢失最后一行。
+type Checkpoint struct {
+	mu     sync.Mutex
+	path   string
+	source string
+	file   *os.File
+	done   rowSet
+
+	hasMeta bool // 已有文件的元信息有效，续写时保留
+	partial bool // 已有文件以不完整的行结尾
+}
+
+// 断点按数据文件的绝对路径区分
+func absSource(source string) string {
+	if abs, err := filepath.Abs(source); err == nil {
+		return abs
+	}
+	return source
+}
+
+// CheckpointPath 返回数据文件对应的断点文件路径
+func CheckpointPath(dir, source string) string {
+	source = absSource(source)
+	sum := sha1.Sum([]byte(source))
+	name := fmt.Sprintf("%s-%s.checkpoint", strings.TrimSuffix(filepath.Base(source), filepath.Ext(source)), hex.EncodeToString(sum[:6]))
+	return filepath.Join(dir, name)
+}
+
+// OpenCheckpoint 打开断点文件。resume 为 true 时加载已完成的行号继续追加，否则清空重新记录
+func OpenCheckpoint(path, source string, resume bool) (*Checkpoint, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建断点目录失败: %v", err)
+	}
+
+	cp := &Checkpoint{path: path, source: absSource(source)}
+	if resume {
+		if err := cp.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !cp.hasMeta {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开断点文件失败: %v", err)
+	}
+	cp.file = file
+
+	var header string
+	if !cp.hasMeta {
+		meta, _ := json.Marshal(checkpointMeta{Source: cp.source, CreatedAt: time.Now()})
+		header = string(meta) + "\n"
+	} else if cp.partial {
+		header = "\n"
+	}
+	if _, err := file.WriteString(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入断点文件失败: %v", err)
+	}
+	return cp, nil
+}
+
+// 读取已有断点文件，忽略最后可能写了一半的行
+func (c *Checkpoint) load() error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		// 连元信息都不完整，视为没有断点
+		return nil
+	}
+	var meta checkpointMeta
+	if err := json.Unmarshal([]byte(line), &meta); err != nil {
+		return fmt.Errorf("断点文件格式错误: %v", err)
+	}
+	if meta.Source != c.source {
+		return fmt.Errorf("断点文件属于另一个数据文件: %s", meta.Source)
+	}
+	c.hasMeta = true
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// 没有换行结尾的行说明写入时被中断，丢弃并在续写前补上换行
+			c.partial = line != ""
+			break
+		}
+		if rowIndex, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+			c.done.add(rowIndex)
+		}
+	}
+	return nil
+}
+
+// Path 断点文件路径
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Done 该行是否在之前的执行中已成功
+func (c *Checkpoint) Done(rowIndex int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done.has(rowIndex)
+}
+
+// Completed 已成功的行数
+func (c *Checkpoint) Completed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done.count
+}
+
+// MarkDone 记录该行已成功
+func (c *Checkpoint) MarkDone(rowIndex int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.done.add(rowIndex) {
+		return nil
+	}
+	_, err := c.file.WriteString(strconv.Itoa(rowIndex) + "\n")
+	return err
+}
+
+// Close 关闭断点文件
+func (c *Checkpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// rowSet 行号集合，每行只占1位，数据文件有上亿行时也只需十几MB
+type rowSet struct {
+	bits  []uint64
+	count int
+}
+
+// 加入行号，已存在时返回 false
+func (s *rowSet) add(rowIndex int) bool {
+	if rowIndex < 0 || s.has(rowIndex) {
+		return false
+	}
+	word := rowIndex / 64
+	if word >= len(s.bits) {
+		bits := make([]uint64, word+1, (word+1)*2)
+		copy(bits, s.bits)
+		s.bits = bits
+	}
+	s.bits[word] |= 1 << uint(rowIndex%64)
+	s.count++
+	return true
+}
+
+func (s *rowSet) has(rowIndex int) bool {
+	word := rowIndex / 64
+	return rowIndex >= 0 && word < len(s.bits) && s.bits[word]&(1<<uint(rowIndex%64)) != 0
+}
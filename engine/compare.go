@@ -0,0 +1,498 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 单行最多列出的差异字段数，超出的不再列出
+const maxFieldDiffs = 50
+
+// 内存中保留的不一致行数上限，用于界面展示和导出，全部结果可通过 jsonl 结果文件获取
+const maxMismatches = 1000
+
+// 差异中值的最大展示长度
+const maxDiffValueSize = 200
+
+// 字段只在一侧存在时另一侧的值
+const missingValue = "<missing>"
+
+// TargetResponse 对比模式下某个目标对一行数据的最终响应
+type TargetResponse struct {
+	Target    string  `json:"target"`
+	Outcome   string  `json:"outcome"`             // 按断言判定的结果分类
+	Status    int     `json:"status"`              // 未收到响应时为0
+	Retries   int     `json:"retries"`             // 重试次数
+	LatencyMs float64 `json:"latencyMs"`           // 最后一次请求耗时
+	Error     string  `json:"error,omitempty"`     // 失败原因
+	Response  string  `json:"response"`            // 响应体（可能被截断）
+	Truncated bool    `json:"truncated,omitempty"` // 响应体是否被截断
+
+	body []byte // 完整响应体，用于比较
+}
+
+// FieldDiff 某个目标与基准目标（IP列表中的第一个）不一致的字段
+type FieldDiff struct {
+	Path   string `json:"path"`   // JSON路径，如 $.data.price；状态码不同时为 status，非JSON响应体为 $
+	Target string `json:"target"` // 与基准不一致的目标
+	Base   string `json:"base"`   // 基准目标的值，不存在时为 <missing>
+	Value  string `json:"value"`  // 该目标的值，不存在时为 <missing>
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s != %s (%s)", d.Path, d.Base, d.Value, d.Target)
+}
+
+// Comparison 对比模式下一行数据在各目标上的响应及差异，Responses 的第一个为基准目标
+type Comparison struct {
+	Responses []TargetResponse `json:"responses"`
+	Diffs     []FieldDiff      `json:"diffs,omitempty"`
+}
+
+// 比较响应前忽略的字段：以 $ 开头的为JSON路径，* 匹配任意键或下标（如 $.list[*].traceId）；
+// 其余为字段名，忽略任意层级的同名字段（如 timestamp、traceId）
+type ignoreRules struct {
+	names map[string]bool
+	paths [][]interface{}
+}
+
+func compileIgnoreRules(fields []string) (*ignoreRules, error) {
+	rules := &ignoreRules{names: make(map[string]bool)}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.HasPrefix(field, "$") {
+			rules.names[field] = true
+			continue
+		}
+		path, err := parseJSONPath(strings.ReplaceAll(field, "[*]", ".*"))
+		if err != nil {
+			return nil, fmt.Errorf("对比忽略字段 %q 格式错误: %v", field, err)
+		}
+		rules.paths = append(rules.paths, path)
+	}
+	return rules, nil
+}
+
+// 该路径上的字段是否忽略
+func (rules *ignoreRules) ignored(path []interface{}) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if name, ok := path[len(path)-1].(string); ok && rules.names[name] {
+		return true
+	}
+	for _, pattern := range rules.paths {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern, path []interface{}) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 比较各目标的响应，返回与基准目标不一致的字段。未收到响应的目标只比较状态
+func (rules *ignoreRules) compare(responses []TargetResponse) []FieldDiff {
+	var diffs []FieldDiff
+	base := responses[0]
+	baseJSON, baseIsJSON := decodeJSON(base.body)
+	for _, resp := range responses[1:] {
+		if resp.Status != base.Status {
+			diffs = append(diffs, FieldDiff{Path: "status", Target: resp.Target, Base: statusText(base), Value: statusText(resp)})
+		}
+		if base.Status == 0 || resp.Status == 0 {
+			continue
+		}
+		if other, ok := decodeJSON(resp.body); baseIsJSON && ok {
+			rules.diff(nil, baseJSON, other, resp.Target, &diffs)
+		} else if !bytes.Equal(bytes.TrimSpace(base.body), bytes.TrimSpace(resp.body)) {
+			diffs = append(diffs, FieldDiff{
+				Path:   "$",
+				Target: resp.Target,
+				Base:   truncateValue(string(base.body)),
+				Value:  truncateValue(string(resp.body)),
+			})
+		}
+	}
+	if len(diffs) > maxFieldDiffs {
+		diffs = diffs[:maxFieldDiffs]
+	}
+	return diffs
+}
+
+// 递归比较两个JSON值，对象按键、数组按下标逐个比较
+func (rules *ignoreRules) diff(path []interface{}, base, other interface{}, target string, diffs *[]FieldDiff) {
+	if len(*diffs) > maxFieldDiffs {
+		return
+	}
+	add := func(path []interface{}, base, other string) {
+		*diffs = append(*diffs, FieldDiff{Path: formatPath(path), Target: target, Base: base, Value: other})
+	}
+	child := func(segment interface{}) []interface{} {
+		return append(append(make([]interface{}, 0, len(path)+1), path...), segment)
+	}
+
+	switch baseValue := base.(type) {
+	case map[string]interface{}:
+		otherValue, ok := other.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(baseValue)+len(otherValue))
+		for key := range baseValue {
+			keys = append(keys, key)
+		}
+		for key := range otherValue {
+			if _, ok := baseValue[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b, inBase := baseValue[key]
+			o, inOther := otherValue[key]
+			switch {
+			case rules.ignored(child(key)):
+			case !inBase:
+				add(child(key), missingValue, truncateValue(jsonText(o)))
+			case !inOther:
+				add(child(key), truncateValue(jsonText(b)), missingValue)
+			default:
+				rules.diff(child(key), b, o, target, diffs)
+			}
+		}
+		return
+	case []interface{}:
+		otherValue, ok := other.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(baseValue) || i < len(otherValue); i++ {
+			switch {
+			case rules.ignored(child(i)):
+			case i >= len(baseValue):
+				add(child(i), missingValue, truncateValue(jsonText(otherValue[i])))
+			case i >= len(otherValue):
+				add(child(i), truncateValue(jsonText(baseValue[i])), missingValue)
+			default:
+				rules.diff(child(i), baseValue[i], otherValue[i], target, diffs)
+			}
+		}
+		return
+	}
+
+	if !sameScalar(base, other) {
+		add(path, truncateValue(jsonText(base)), truncateValue(jsonText(other)))
+	}
+}
+
+// 比较两个非容器的JSON值，数字按数值比较（1 与 1.0 相同），类型不同时视为不同
+func sameScalar(base, other interface{}) bool {
+	if b, ok := base.(json.Number); ok {
+		o, ok := other.(json.Number)
+		if !ok {
+			return false
+		}
+		bf, errB := b.Float64()
+		of, errO := o.Float64()
+		if errB == nil && errO == nil {
+			return bf == of
+		}
+		return b == o
+	}
+	if _, ok := other.(json.Number); ok {
+		return false
+	}
+	switch base.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	switch other.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return base == other
+}
+
+func decodeJSON(body []byte) (interface{}, bool) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// 路径的文本形式，如 $.data.list[0].name，键中含特殊字符时使用 ['key']
+func formatPath(path []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case int:
+			fmt.Fprintf(&sb, "[%d]", key)
+		case string:
+			if key == "" || strings.ContainsAny(key, ".[]'\" ") {
+				fmt.Fprintf(&sb, "['%s']", key)
+			} else {
+				sb.WriteString("." + key)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// 差异中展示的状态，未收到响应时为失败原因
+func statusText(resp TargetResponse) string {
+	if resp.Status == 0 {
+		return truncateValue("no response: " + resp.Error)
+	}
+	return strconv.Itoa(resp.Status)
+}
+
+func truncateValue(value string) string {
+	if len(value) > maxDiffValueSize {
+		return value[:maxDiffValueSize] + "..."
+	}
+	return value
+}
+
+// 对比模式：把一行数据并发发送到每个目标，按断言判定各目标的结果后比较响应。
+// 任一目标被取消或参数错误时该行按相应结果处理，响应不一致时为 mismatch，一致时使用基准目标的结果
+func (r *Runner) compareRequest(ctx context.Context, task RequestTask, maxRetries int) (*Result, string) {
+	startTime := time.Now()
+	responses := make([]TargetResponse, len(r.compareTargets))
+	var wg sync.WaitGroup
+	for i, target := range r.compareTargets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			responses[i] = r.fetch(ctx, task, target, maxRetries)
+		}(i, target)
+	}
+	wg.Wait()
+
+	base := responses[0]
+	result := &Result{
+		RowIndex:  task.RowIndex,
+		Row:       task.Row,
+		Params:    string(task.ParamsJSON),
+		Target:    base.Target,
+		Status:    base.Status,
+		Retries:   base.Retries,
+		LatencyMs: base.LatencyMs,
+		ElapsedMs: durationMs(time.Since(startTime)),
+		Response:  base.Response,
+		Truncated: base.Truncated,
+		Compare:   &Comparison{Responses: responses},
+	}
+
+	for _, resp := range responses {
+		switch resp.Outcome {
+		case ResultCancelled:
+			result.Outcome, result.Error = ResultCancelled, resp.Error
+			return result, fmt.Sprintf("Row %d cancelled", task.RowIndex)
+		case ResultParamError:
+			result.Outcome, result.Error = ResultParamError, resp.Error
+			return result, fmt.Sprintf("Row %d %s", task.RowIndex, resp.Error)
+		}
+	}
+
+	diffs := r.ignore.compare(responses)
+	if len(diffs) > 0 {
+		result.Compare.Diffs = diffs
+		result.Outcome = ResultMismatch
+		result.Error = fmt.Sprintf("responses differ in %d fields", len(diffs))
+		shown := make([]string, 0, 3)
+		for _, diff := range diffs {
+			if len(shown) == cap(shown) {
+				shown = append(shown, "...")
+				break
+			}
+			shown = append(shown, diff.String())
+		}
+		return result, fmt.Sprintf("Row %d responses differ across targets: %s", task.RowIndex, strings.Join(shown, "; "))
+	}
+
+	// 各目标响应一致，按基准目标的断言结果判定
+	result.Outcome, result.Error = base.Outcome, base.Error
+	result.Success = base.Outcome == ResultSuccess
+	if result.Success {
+		return result, fmt.Sprintf("Row %d consistent across %d targets in %v: %s",
+			task.RowIndex, len(responses), time.Since(startTime), base.Response)
+	}
+	return result, fmt.Sprintf("Row %d consistent across %d targets but failed with status %d, %s",
+		task.RowIndex, len(responses), base.Status, base.Error)
+}
+
+// 向指定目标发送一行数据的请求，按断言规则重试，返回最后一次的响应。
+// 对比模式下每个目标都要请求，不经过负载均衡和熔断
+func (r *Runner) fetch(ctx context.Context, task RequestTask, target string, maxRetries int) TargetResponse {
+	resp := TargetResponse{Target: target}
+	vars := &templateVars{
+		row:      splitRow(task.Row),
+		rowIndex: task.RowIndex,
+		params:   task.ParamsJSON,
+		ip:       target,
+	}
+
+	for retryCount := 0; retryCount < maxRetries; retryCount++ {
+		if retryCount > 0 {
+			waitBackoff(ctx, retryCount)
+		}
+		if ctx.Err() != nil {
+			resp.Outcome, resp.Error = ResultCancelled, ctx.Err().Error()
+			return resp
+		}
+		resp.Retries = retryCount
+
+		reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		req, err := r.newRequest(reqCtx, vars)
+		if err != nil {
+			cancel()
+			resp.Outcome, resp.Error = ResultParamError, err.Error()
+			return resp
+		}
+
+		requestStart := time.Now()
+		httpResp, err := r.client.Do(req)
+		requestDuration := time.Since(requestStart)
+		resp.LatencyMs = durationMs(requestDuration)
+		if err != nil {
+			cancel()
+			if ctx.Err() != nil {
+				resp.Outcome, resp.Error = ResultCancelled, ctx.Err().Error()
+				return resp
+			}
+			r.stats.record(target, requestDuration, false, true)
+			resp.Status, resp.Error = 0, err.Error()
+			r.rowLogf(task.RowIndex, "Row %d target %s request failed (retry %d/%d): %v",
+				task.RowIndex, target, retryCount+1, maxRetries, err)
+			continue
+		}
+
+		body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+		httpResp.Body.Close()
+		cancel()
+		if err != nil {
+			r.stats.record(target, requestDuration, false, true)
+			resp.Status, resp.Error = 0, fmt.Sprintf("response read failed: %v", err)
+			r.rowLogf(task.RowIndex, "Row %d target %s response read failed (retry %d/%d): %v",
+				task.RowIndex, target, retryCount+1, maxRetries, err)
+			continue
+		}
+		resp.Status, resp.body = httpResp.StatusCode, body
+		resp.Response, resp.Truncated = truncateResponse(body)
+
+		outcome, reason := evaluateAssertions(r.assertions, r.assertDefault(), &response{
+			status:  httpResp.StatusCode,
+			header:  httpResp.Header,
+			body:    body,
+			latency: requestDuration,
+		})
+		r.stats.record(target, requestDuration, true, outcome != OutcomeSuccess)
+		switch outcome {
+		case OutcomeRetry:
+			resp.Error = reason
+			r.rowLogf(task.RowIndex, "Row %d target %s status %d, %s (retry %d/%d)",
+				task.RowIndex, target, httpResp.StatusCode, reason, retryCount+1, maxRetries)
+			continue
+		case OutcomeFail:
+			resp.Outcome, resp.Error = failedOutcome(httpResp.StatusCode), reason
+			return resp
+		}
+		resp.Outcome, resp.Error = ResultSuccess, ""
+		return resp
+	}
+	resp.Outcome = ResultExhausted
+	return resp
+}
+
+// 记录不一致的行，超过上限后只计数
+func (r *Runner) recordMismatch(result *Result) {
+	if len(r.mismatches) < maxMismatches {
+		r.mismatches = append(r.mismatches, result)
+	}
+}
+
+// 本次执行中不一致的行，按行号排序，执行结束后调用
+func (r *Runner) mismatchRows() []*Result {
+	r.countsMu.Lock()
+	defer r.countsMu.Unlock()
+	rows := append([]*Result(nil), r.mismatches...)
+	sort.Slice(rows, func(i, k int) bool {
+		return rows[i].RowIndex < rows[k].RowIndex
+	})
+	return rows
+}
+
+// 不一致行导出文件的列
+var mismatchColumns = []string{"rowIndex", "params", "path", "baseTarget", "base", "target", "value"}
+
+// WriteMismatches 导出对比模式下不一致的行：.jsonl 每行一个完整结果（含各目标的响应），
+// 其余为CSV格式，每个差异字段一行
+func WriteMismatches(path string, results []*Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	if ResultFormatFromPath(path) == ResultFormatJSONL {
+		sink := NewJSONLResultSink(file)
+		for _, result := range results {
+			if err := sink.Write(result); err != nil {
+				return err
+			}
+		}
+		return sink.Close()
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(mismatchColumns); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Compare == nil {
+			continue
+		}
+		for _, diff := range result.Compare.Diffs {
+			record := []string{
+				strconv.Itoa(result.RowIndex), result.Params, diff.Path,
+				result.Target, diff.Base, diff.Target, diff.Value,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParamMapping 参数映射配置
+type ParamMapping struct {
+	CSVColumn    string `json:"csvColumn"`    // CSV列名或索引
+	ParamName    string `json:"paramName"`    // 请求参数名（对象模式）或数组索引（数组模式）
+	ParamType    string `json:"paramType"`    // 参数类型：string, int, float, bool
+	DefaultValue string `json:"defaultValue"` // 默认值
+	ArrayIndex   int    `json:"arrayIndex"`   // 数组模式下的参数位置索引
+}
+
+// Header 请求头配置，值支持模板占位符
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// 请求体格式，决定模板的编译方式和 Content-Type
+const (
+	BodyFormatJSON = "json"
+	BodyFormatForm = "form"
+	BodyFormatText = "text"
+)
+
+// 参数生成模式
+const (
+	ParamModeObject      = "object"
+	ParamModeArray       = "array"
+	ParamModePassthrough = "passthrough" // JSONL 数据的每行原样作为参数
+)
+
+// ParamModes 支持的参数生成模式
+var ParamModes = []string{ParamModeObject, ParamModeArray, ParamModePassthrough}
+
+// BodyFormats 支持的请求体格式
+var BodyFormats = []string{BodyFormatJSON, BodyFormatForm, BodyFormatText}
+
+// Config 配置结构
+type Config struct {
+	Method           string         `json:"method,omitempty"` // HTTP方法，为空时使用POST
+	URL              string         `json:"url"`              // 请求地址，路径和查询串支持占位符
+	Cookie           string         `json:"cookie"`
+	BodyTemp         string         `json:"bodyTemp"`
+	BodyFormat       string         `json:"bodyFormat,omitempty"` // 请求体格式：json、form 或 text，为空时为 json
+	Headers          []Header       `json:"headers,omitempty"`    // 自定义请求头，覆盖按请求体格式生成的 Content-Type
+	IPList           []string       `json:"ipList"`
+	Environments     []Environment  `json:"environments,omitempty"`     // 命名环境，模板和IP列表中用 ${var:名称} 引用
+	Environment      string         `json:"environment,omitempty"`      // 当前使用的环境，为空时不使用环境变量
+	BreakerThreshold int            `json:"breakerThreshold,omitempty"` // 目标连续失败多少次后熔断，0 为不熔断（未配置时为 0，图形界面默认填 5）
+	BreakerCooldown  int            `json:"breakerCooldown,omitempty"`  // 熔断后多少秒放行探测请求，为空时为10秒
+	Preflight        string         `json:"preflight,omitempty"`        // 执行前检查目标：tcp、http，为空时不检查
+	Balance          string         `json:"balance,omitempty"`          // 负载均衡策略，为空时为 round-robin
+	BalanceKey       string         `json:"balanceKey,omitempty"`       // hash 策略使用的列名或列索引
+	PreflightPath    string         `json:"preflightPath,omitempty"`    // http 预检请求的路径
+	CompareMode      bool           `json:"compareMode,omitempty"`      // 对比模式：每行发送到所有目标并比较响应
+	CompareIgnore    []string       `json:"compareIgnore,omitempty"`    // 对比时忽略的字段名或JSON路径，如 traceId、$.data.time
+	QPS              int            `json:"qps"`
+	Profile          []Stage        `json:"profile,omitempty"` // 流量曲线，按阶段调整QPS，结束后使用 QPS
+	Workers          int            `json:"workers"`
+	MaxRetries       int            `json:"maxRetries"`
+	Assertions       []Assertion    `json:"assertions,omitempty"`    // 响应断言规则，为空时使用 DefaultAssertions
+	AssertDefault    string         `json:"assertDefault,omitempty"` // 没有断言命中时的结果，为空时为 success
+	InputFormat      string         `json:"inputFormat,omitempty"`   // 数据文件格式：csv、jsonl 或 xlsx，为空时为 csv
+	Encoding         string         `json:"encoding,omitempty"`      // CSV / JSONL 数据的字符编码，为空或 auto 时自动检测
+	Sheet            string         `json:"sheet,omitempty"`         // Excel 数据使用的工作表，为空时为第一个工作表
+	HeaderRow        int            `json:"headerRow,omitempty"`     // Excel 数据的标题行在工作表中的行号，为空时为第1行
+	ParamMappings    []ParamMapping `json:"paramMappings"`           // 参数映射配置，JSONL 数据按JSON路径取值
+	ParamMode        string         `json:"paramMode"`               // 参数生成模式：object(对象)、array(数组) 或 passthrough(透传，仅JSONL)
+	ResultFile       string         `json:"resultFile,omitempty"`    // 逐行结果输出文件，为空时不输出
+	ResultFormat     string         `json:"resultFormat,omitempty"`  // 结果文件格式：csv、jsonl 或 xlsx，为空时按扩展名判断
+
+	// 已解锁保险库中的密钥，供 ${secret:名称} 引用，不写入配置文件
+	Secrets map[string]string `json:"-"`
+}
+
+// ConfigError 配置有误导致无法开始执行，如缺少必需字段、模板或参数映射错误。
+// Run 返回的其他错误（如预检全部失败、结果文件无法写入）不属于此类
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// Validate 校验配置中运行必需的字段
+func (c *Config) Validate() error {
+	if strings.TrimSpace(c.URL) == "" {
+		return fmt.Errorf("请求URL不能为空")
+	}
+	if !isSupportedMethod(c.RequestMethod()) {
+		return fmt.Errorf("不支持的请求方法: %s", c.Method)
+	}
+	if contentTypes[c.RequestBodyFormat()] == "" {
+		return fmt.Errorf("不支持的请求体格式: %s", c.BodyFormat)
+	}
+	if c.AssertDefault != "" && !contains(Outcomes, c.AssertDefault) {
+		return fmt.Errorf("不支持的默认断言结果: %s", c.AssertDefault)
+	}
+	for _, header := range c.Headers {
+		if name := strings.TrimSpace(header.Name); name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("请求头名称无效: %q", header.Name)
+		}
+	}
+	if c.QPS <= 0 {
+		return fmt.Errorf("QPS必须大于0")
+	}
+	if c.Workers <= 0 {
+		return fmt.Errorf("并发数必须大于0")
+	}
+	if err := validateStages(c.Profile); err != nil {
+		return err
+	}
+	if c.MaxRetries <= 0 {
+		return fmt.Errorf("重试次数必须大于0")
+	}
+	if err := validateEnvironments(c); err != nil {
+		return err
+	}
+	ips, err := c.IPs()
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("IP地址列表不能为空")
+	}
+	if _, err := parseTargets(ips); err != nil {
+		return err
+	}
+	if c.Balance != "" && !contains(Balances, c.Balance) {
+		return fmt.Errorf("不支持的负载均衡策略: %s", c.Balance)
+	}
+	if c.Balance == BalanceHash && strings.TrimSpace(c.BalanceKey) == "" {
+		return fmt.Errorf("hash 负载均衡需要指定用于哈希的列")
+	}
+	if c.BreakerThreshold < 0 || c.BreakerCooldown < 0 {
+		return fmt.Errorf("熔断阈值和冷却时间不能为负数")
+	}
+	if !contains(Preflights, c.Preflight) {
+		return fmt.Errorf("不支持的预检方式: %s", c.Preflight)
+	}
+	if !contains(InputFormats, c.SourceFormat()) {
+		return fmt.Errorf("不支持的数据文件格式: %s", c.InputFormat)
+	}
+	if !contains(Encodings, c.DataEncoding()) {
+		return fmt.Errorf("不支持的文件编码: %s", c.Encoding)
+	}
+	if c.ParamMode == ParamModePassthrough && c.SourceFormat() != InputFormatJSONL {
+		return fmt.Errorf("透传模式只适用于 JSONL 数据")
+	}
+	if c.HeaderRow < 0 {
+		return fmt.Errorf("标题行不能为负数")
+	}
+	if c.ResultFile != "" && c.SourceFormat() != InputFormatXLSX &&
+		(c.ResultFormat == ResultFormatXLSX || c.ResultFormat == "" && ResultFormatFromPath(c.ResultFile) == ResultFormatXLSX) {
+		return fmt.Errorf("Excel 结果文件需要使用 Excel 数据文件")
+	}
+	if c.CompareMode && len(ips) < 2 {
+		return fmt.Errorf("对比模式至少需要两个目标")
+	}
+	if _, err := compileIgnoreRules(c.CompareIgnore); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Methods 支持的HTTP方法
+var Methods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut,
+	http.MethodDelete, http.MethodPatch, http.MethodHead,
+}
+
+func isSupportedMethod(method string) bool {
+	for _, m := range Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestMethod 返回实际使用的HTTP方法，未配置时为POST
+func (c *Config) RequestMethod() string {
+	method := strings.ToUpper(strings.TrimSpace(c.Method))
+	if method == "" {
+		return http.MethodPost
+	}
+	return method
+}
+
+// RequestAssertions 返回实际使用的断言规则，未配置时为默认规则
+func (c *Config) RequestAssertions() []Assertion {
+	if len(c.Assertions) == 0 {
+		return DefaultAssertions()
+	}
+	return c.Assertions
+}
+
+// 各请求体格式对应的 Content-Type
+var contentTypes = map[string]string{
+	BodyFormatJSON: "application/json;charset=UTF-8",
+	BodyFormatForm: "application/x-www-form-urlencoded;charset=UTF-8",
+	BodyFormatText: "text/plain;charset=UTF-8",
+}
+
+// RequestBodyFormat 返回实际使用的请求体格式，未配置时为JSON
+func (c *Config) RequestBodyFormat() string {
+	format := strings.ToLower(strings.TrimSpace(c.BodyFormat))
+	if format == "" {
+		return BodyFormatJSON
+	}
+	return format
+}
+
+// JSFHeaders 原先固定发送的JSF网关请求头，作为可选预设。
+// 不包含 Accept-Encoding，由HTTP客户端自动协商并解压。
+func JSFHeaders() []Header {
+	return []Header{
+		{Name: "Accept", Value: "application/json, text/plain, */*"},
+		{Name: "Accept-Language", Value: "zh-CN,zh;q=0.9"},
+		{Name: "Cache-Control", Value: "no-cache"},
+		{Name: "Connection", Value: "keep-alive"},
+		{Name: "Host", Value: "intest-manager.jd.com"},
+		{Name: "Origin", Value: "http://xingyun.jd.com"},
+		{Name: "Pragma", Value: "no-cache"},
+		{Name: "Referer", Value: "http://xingyun.jd.com/deeptest/quicktest/list?env=master&parentId=21254&Id=137790"},
+		{Name: "User-Agent", Value: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36"},
+	}
+}
+
+// 该方法是否携带请求体
+func methodHasBody(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead
+}
+
+// 去掉IP列表中的空白和空行
+func cleanIPList(ipList []string) []string {
+	cleaned := make([]string, 0, len(ipList))
+	for _, ip := range ipList {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			cleaned = append(cleaned, ip)
+		}
+	}
+	return cleaned
+}
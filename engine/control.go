@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+)
+
+// workerPool 可在执行中调整大小的worker池。
+// 缩容时多余的worker在处理完当前请求后退出，不会丢弃进行中的任务
+type workerPool struct {
+	mu      sync.Mutex
+	target  int
+	active  int
+	resized chan struct{} // 目标数量变化时关闭，唤醒空闲的worker检查是否需要退出
+	spawn   func()        // 启动一个worker，执行期间有效
+}
+
+func newWorkerPool(target int, spawn func()) *workerPool {
+	return &workerPool{target: target, resized: make(chan struct{}), spawn: spawn}
+}
+
+// 调整目标数量，不足时立即启动新的worker
+func (p *workerPool) resize(target int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.target = target
+	for p.active < p.target {
+		p.active++
+		p.spawn()
+	}
+	close(p.resized)
+	p.resized = make(chan struct{})
+}
+
+// worker在领取任务前调用：超出目标数量时登记退出并返回 true，否则返回用于等待的通知通道
+func (p *workerPool) retire() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active > p.target {
+		p.active--
+		return true, nil
+	}
+	return false, p.resized
+}
+
+// worker异常退出时调用，保持计数准确
+func (p *workerPool) exited() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+}
+
+// SetQPS 调整QPS，执行中调用时立即生效
+func (r *Runner) SetQPS(qps int) error {
+	if qps <= 0 {
+		return fmt.Errorf("QPS必须大于0")
+	}
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	r.qps = qps
+	if r.limiter != nil {
+		if r.profileActive {
+			r.profileActive = false
+			r.logf("Traffic profile stopped by manual QPS change")
+		}
+		r.limiter.setRate(float64(qps))
+		r.logf("QPS changed to %d", qps)
+	}
+	return nil
+}
+
+// 当前的目标QPS，未在执行时返回设置的QPS
+func (r *Runner) targetQPS() float64 {
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	if r.limiter != nil {
+		return r.limiter.rate()
+	}
+	return float64(r.qps)
+}
+
+// SetWorkers 调整并发数，执行中调用时立即生效，缩容不会中断进行中的请求
+func (r *Runner) SetWorkers(workers int) error {
+	if workers <= 0 {
+		return fmt.Errorf("并发数必须大于0")
+	}
+	r.controlMu.Lock()
+	defer r.controlMu.Unlock()
+	r.workers = workers
+	if r.pool != nil {
+		r.pool.resize(workers)
+		r.logf("Workers changed to %d", workers)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 执行中调整QPS和并发数只影响本次执行，不修改调用方的配置
+func TestLiveControlKeepsConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	config := testConfig(server)
+	var r *Runner
+	var change sync.Once
+	r = NewRunner(config, func(event Event) {
+		if event.Type != EventRowStarted {
+			return
+		}
+		change.Do(func() {
+			if err := r.SetQPS(500); err != nil {
+				t.Error(err)
+			}
+			if err := r.SetWorkers(3); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+	summary, err := r.Run(context.Background(), NewCSVSource(strings.NewReader(csvRows(5))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Success != 5 {
+		t.Errorf("succeeded = %d, want 5", summary.Success)
+	}
+	if config.QPS != 1000 || config.Workers != 1 {
+		t.Errorf("config changed to QPS %d, workers %d", config.QPS, config.Workers)
+	}
+	if got := r.targetQPS(); got != 500 {
+		t.Errorf("targetQPS = %v, want 500", got)
+	}
+	if err := r.SetQPS(0); err == nil {
+		t.Error("SetQPS(0) accepted")
+	}
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// 数据文件的字符编码
+const (
+	EncodingAuto    = "auto" // 按文件开头的 BOM 和内容自动检测
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom"
+	EncodingGBK     = "gbk"
+	EncodingGB18030 = "gb18030"
+	EncodingUTF16   = "utf-16" // 按 BOM 判断字节序，没有 BOM 时为小端
+)
+
+// Encodings 支持的数据文件编码
+var Encodings = []string{EncodingAuto, EncodingUTF8, EncodingUTF8BOM, EncodingGBK, EncodingGB18030, EncodingUTF16}
+
+// 自动检测时读取的文件开头字节数
+const detectSize = 64 << 10
+
+// DataEncoding 返回配置的数据文件编码，未配置时为自动检测
+func (c *Config) DataEncoding() string {
+	name := strings.ToLower(strings.TrimSpace(c.Encoding))
+	if name == "" {
+		return EncodingAuto
+	}
+	return name
+}
+
+// DetectEncoding 根据数据开头的字节判断编码：有 BOM 时按 BOM，是合法的 UTF-8 时为 UTF-8，
+// 大量 0 字节时为没有 BOM 的 UTF-16，否则为中文 Windows 上 Excel 导出的 GB18030（兼容 GBK）
+func DetectEncoding(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8BOM
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}), bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return EncodingUTF16
+	case looksLikeUTF16(head):
+		return EncodingUTF16
+	case validUTF8Prefix(head):
+		return EncodingUTF8
+	default:
+		return EncodingGB18030
+	}
+}
+
+// DetectFileEncoding 读取文件开头判断编码
+func DetectFileEncoding(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开数据文件失败: %v", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, detectSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("读取数据文件失败: %v", err)
+	}
+	return DetectEncoding(head[:n]), nil
+}
+
+// 文件开头可能在多字节字符中间截断，去掉末尾不完整的字符后再判断
+func validUTF8Prefix(head []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
+
+// ASCII 为主的文本按 UTF-16 保存时，每两个字节中有一个是 0
+func looksLikeUTF16(head []byte) bool {
+	if len(head) < 4 {
+		return false
+	}
+	zeros := bytes.Count(head, []byte{0})
+	return zeros*4 >= len(head)
+}
+
+// 按编码名称取解码器，UTF-8 也会去掉开头的 BOM，避免第一列的列名带上 BOM
+func decoderFor(name string) (*encoding.Decoder, error) {
+	switch name {
+	case EncodingUTF8, EncodingUTF8BOM:
+		return unicode.UTF8BOM.NewDecoder(), nil
+	case EncodingGBK:
+		return simplifiedchinese.GBK.NewDecoder(), nil
+	case EncodingGB18030:
+		return simplifiedchinese.GB18030.NewDecoder(), nil
+	case EncodingUTF16:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder(), nil
+	}
+	return nil, fmt.Errorf("不支持的文件编码: %s", name)
+}
+
+// 按编码写出数据，Close 时写出缓冲中剩余的内容（不关闭 w）。无法用该编码表示的字符写为替代字符
+func encodeWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	var encoder *encoding.Encoder
+	switch name {
+	case EncodingUTF8:
+		encoder = encoding.Nop.NewEncoder()
+	case EncodingUTF8BOM:
+		encoder = unicode.UTF8BOM.NewEncoder()
+	case EncodingGBK:
+		encoder = simplifiedchinese.GBK.NewEncoder()
+	case EncodingGB18030:
+		encoder = simplifiedchinese.GB18030.NewEncoder()
+	case EncodingUTF16:
+		encoder = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	default:
+		return nil, fmt.Errorf("不支持的文件编码: %s", name)
+	}
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(encoder)), nil
+}
+
+// 把数据转为 UTF-8 后再交给 CSV / JSONL 解析，name 为 auto 时先检测编码，
+// buffered 的缓冲区需要能容纳 detectSize 字节。返回实际使用的编码
+func decodeReader(buffered *bufio.Reader, name string) (io.Reader, string, error) {
+	if name == EncodingAuto {
+		head, err := buffered.Peek(detectSize)
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		name = DetectEncoding(head)
+	}
+	decoder, err := decoderFor(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return transform.NewReader(buffered, decoder), name, nil
+}
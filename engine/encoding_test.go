@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	gbk := []byte{0xB6, 0xA9, 0xB5, 0xA5, ',', 'i', 'd', '\n'} // "订单,id"
+	utf16 := []byte{'i', 0, 'd', 0, ',', 0, 'n', 0, '\n', 0}
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"empty", nil, EncodingUTF8},
+		{"ascii", []byte("id,name\n1,a\n"), EncodingUTF8},
+		{"utf-8", []byte("订单,金额\n"), EncodingUTF8},
+		{"utf-8 cut mid character", []byte("订单")[:5], EncodingUTF8},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "id"...), EncodingUTF8BOM},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, utf16...), EncodingUTF16},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0, 'i', 0, 'd'}, EncodingUTF16},
+		{"utf-16 without bom", utf16, EncodingUTF16},
+		{"gbk", gbk, EncodingGB18030},
+	}
+	for _, tt := range tests {
+		if got := DetectEncoding(tt.head); got != tt.want {
+			t.Errorf("%s: DetectEncoding = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 按检测或指定的编码解码后，第一列的列名不带 BOM，中文内容正确
+func TestDecodeReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		want     string
+		detected string
+	}{
+		{"auto utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "订单,id\n"...), EncodingAuto, "订单,id\n", EncodingUTF8BOM},
+		{"auto gbk", []byte{0xB6, 0xA9, 0xB5, 0xA5, ',', 'i', 'd', '\n'}, EncodingAuto, "订单,id\n", EncodingGB18030},
+		{"auto utf-16", []byte{0xFF, 0xFE, 0xA2, 0x8B, 0x55, 0x53, ',', 0, '\n', 0}, EncodingAuto, "订单,\n", EncodingUTF16},
+		{"manual gbk", []byte{0xB6, 0xA9, 0xB5, 0xA5}, EncodingGBK, "订单", EncodingGBK},
+		{"manual utf-8 strips bom", append([]byte{0xEF, 0xBB, 0xBF}, "id"...), EncodingUTF8, "id", EncodingUTF8},
+	}
+	for _, tt := range tests {
+		decoded, name, err := decodeReader(bufio.NewReaderSize(bytes.NewReader(tt.data), detectSize), tt.encoding)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(decoded)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(got) != tt.want || name != tt.detected {
+			t.Errorf("%s: decoded %q as %s, want %q as %s", tt.name, got, name, tt.want, tt.detected)
+		}
+	}
+	if _, _, err := decodeReader(bufio.NewReader(bytes.NewReader(nil)), "latin1"); err == nil {
+		t.Error("unsupported encoding accepted")
+	}
+}
+
+// 导出的文件使用数据文件原来的编码，重新检测得到同样的编码
+func TestEncodeWriterRoundTrip(t *testing.T) {
+	for _, name := range []string{EncodingUTF8, EncodingUTF8BOM, EncodingGB18030, EncodingUTF16} {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := encodeWriter(file, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, "订单,金额\n1,2\n")
+		w.Close()
+		file.Close()
+
+		detected, err := DetectFileEncoding(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detected != name {
+			t.Errorf("%s: re-detected as %s", name, detected)
+		}
+	}
+}
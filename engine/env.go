@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Environment 命名环境，如 test、pre、prod。请求地址、请求头、请求体模板、Cookie 和IP列表
+// 可以用 ${var:名称} 引用当前环境的变量，同一份配置切换环境即可在不同环境执行
+type Environment struct {
+	Name string            `json:"name"`
+	Vars map[string]string `json:"vars"`
+}
+
+// ActiveVars 当前环境的变量，未选择环境时为空
+func (c *Config) ActiveVars() (map[string]string, error) {
+	if c.Environment == "" {
+		return nil, nil
+	}
+	for _, env := range c.Environments {
+		if env.Name == c.Environment {
+			return expandVarSecrets(env.Vars, c.Secrets)
+		}
+	}
+	return nil, fmt.Errorf("环境 %q 不存在", c.Environment)
+}
+
+// 当前环境中值引用了保险库密钥的变量，预览时隐藏这些变量的值
+func (c *Config) secretVars() map[string]bool {
+	names := make(map[string]bool)
+	for _, env := range c.Environments {
+		if env.Name != c.Environment {
+			continue
+		}
+		for name, value := range env.Vars {
+			if strings.Contains(value, secretPrefix) {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// 变量的值可以引用保险库中的密钥，有引用时返回展开后的副本
+func expandVarSecrets(vars map[string]string, secrets map[string]string) (map[string]string, error) {
+	var expanded map[string]string
+	for name, value := range vars {
+		if !strings.Contains(value, secretPrefix) {
+			continue
+		}
+		if expanded == nil {
+			expanded = make(map[string]string, len(vars))
+			for k, v := range vars {
+				expanded[k] = v
+			}
+		}
+		var err error
+		if expanded[name], err = expandSecrets(value, secrets); err != nil {
+			return nil, fmt.Errorf("变量 %s %v", name, err)
+		}
+	}
+	if expanded == nil {
+		return vars, nil
+	}
+	return expanded, nil
+}
+
+// IPs 展开环境变量并去掉空行后的IP列表。变量的值可以包含多个目标，用逗号或分号分隔
+func (c *Config) IPs() ([]string, error) {
+	vars, err := c.ActiveVars()
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, line := range cleanIPList(c.IPList) {
+		expanded, err := expandVars(line, vars)
+		if err != nil {
+			return nil, fmt.Errorf("IP列表 %s", err)
+		}
+		ips = append(ips, cleanIPList(strings.FieldsFunc(expanded, func(r rune) bool {
+			return r == ',' || r == ';'
+		}))...)
+	}
+	return ips, nil
+}
+
+// TargetScheme 请求地址使用的协议（http 或 https），HTTP 预检按同样的协议访问目标。
+// 地址以变量开头时按变量的值判断，无法判断时为 http
+func (c *Config) TargetScheme() string {
+	rawURL := strings.TrimSpace(c.URL)
+	if vars, err := c.ActiveVars(); err == nil {
+		if expanded, err := expandVars(rawURL, vars); err == nil {
+			rawURL = expanded
+		}
+	}
+	if scheme, _, ok := strings.Cut(rawURL, "://"); ok && strings.EqualFold(scheme, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+func validateEnvironments(c *Config) error {
+	seen := make(map[string]bool)
+	for _, env := range c.Environments {
+		name := strings.TrimSpace(env.Name)
+		if name == "" {
+			return fmt.Errorf("环境名称不能为空")
+		}
+		if seen[name] {
+			return fmt.Errorf("环境名称重复: %s", name)
+		}
+		seen[name] = true
+		for key := range env.Vars {
+			if err := validateVarName(key); err != nil {
+				return fmt.Errorf("环境 %s 的%v", name, err)
+			}
+		}
+	}
+	_, err := c.ActiveVars()
+	return err
+}
+
+func validateVarName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t=${}:") {
+		return fmt.Errorf("变量名无效: %q", name)
+	}
+	return nil
+}
+
+// 展开文本中的 ${var:名称}，其他占位符原样保留
+func expandVars(text string, vars map[string]string) (string, error) {
+	return expandRefs(text, "${var:", func(name string) (string, error) {
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("引用的变量 %s 在当前环境中未定义", name)
+		}
+		return value, nil
+	})
+}
+
+// 展开文本中以 prefix 开头的占位符，lookup 按名称取值
+func expandRefs(text, prefix string, lookup func(name string) (string, error)) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(text, prefix)
+		if start < 0 {
+			sb.WriteString(text)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("占位符缺少 }: %s", text[start:])
+		}
+		value, err := lookup(strings.TrimSpace(text[start+len(prefix) : start+end]))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(text[:start])
+		sb.WriteString(value)
+		text = text[start+end+1:]
+	}
+}
+
+// ParseVars 解析环境变量文本，每行一个 "名称=值"，值可以为空。空行和 # 开头的行忽略
+func ParseVars(text string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("环境变量第%d行格式错误，应为 \"名称=值\": %s", i+1, line)
+		}
+		name = strings.TrimSpace(name)
+		if err := validateVarName(name); err != nil {
+			return nil, fmt.Errorf("环境变量第%d行%v", i+1, err)
+		}
+		vars[name] = strings.TrimSpace(value)
+	}
+	return vars, nil
+}
+
+// FormatVars 把环境变量转为 ParseVars 使用的文本，按名称排序
+func FormatVars(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, name+"="+vars[name])
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType 执行事件类型
+type EventType int
+
+const (
+	EventLog          EventType = iota // 普通日志
+	EventRowStarted                    // 某行开始发送
+	EventRowSucceeded                  // 某行最终成功
+	EventRowFailed                     // 某行最终失败（含参数生成失败）
+	EventProgress                      // 执行进度
+	EventSample                        // 每秒一次的实时采样，用于绘制曲线
+)
+
+// Counts 按最终结果分类的行数
+type Counts struct {
+	Success      int `json:"success"`          // 成功
+	ClientError  int `json:"clientError"`      // 4xx 判定失败
+	ServerError  int `json:"serverError"`      // 5xx 判定失败
+	AssertFailed int `json:"assertFailed"`     // 其他判定失败
+	Exhausted    int `json:"retriesExhausted"` // 重试次数用尽
+	Cancelled    int `json:"cancelled"`        // 停止时请求未完成
+	ParamErrors  int `json:"paramErrors"`      // 参数生成或模板渲染失败
+	Mismatch     int `json:"mismatch"`         // 对比模式下各目标响应不一致
+	Skipped      int `json:"skipped"`          // 断点续跑时跳过的已成功行
+}
+
+func (c *Counts) add(outcome string) {
+	switch outcome {
+	case ResultSuccess:
+		c.Success++
+	case ResultClientError:
+		c.ClientError++
+	case ResultServerError:
+		c.ServerError++
+	case ResultAssertFailed:
+		c.AssertFailed++
+	case ResultExhausted:
+		c.Exhausted++
+	case ResultCancelled:
+		c.Cancelled++
+	case ResultMismatch:
+		c.Mismatch++
+	default:
+		c.ParamErrors++
+	}
+}
+
+// 两次统计之间各分类的增量
+func (c Counts) sub(prev Counts) Counts {
+	return Counts{
+		Success:      c.Success - prev.Success,
+		ClientError:  c.ClientError - prev.ClientError,
+		ServerError:  c.ServerError - prev.ServerError,
+		AssertFailed: c.AssertFailed - prev.AssertFailed,
+		Exhausted:    c.Exhausted - prev.Exhausted,
+		Cancelled:    c.Cancelled - prev.Cancelled,
+		ParamErrors:  c.ParamErrors - prev.ParamErrors,
+		Mismatch:     c.Mismatch - prev.Mismatch,
+		Skipped:      c.Skipped - prev.Skipped,
+	}
+}
+
+// Failed 最终失败的行数（不含被取消的行）
+func (c Counts) Failed() int {
+	return c.ClientError + c.ServerError + c.AssertFailed + c.Exhausted + c.ParamErrors + c.Mismatch
+}
+
+// Done 已有最终结果的行数，含跳过的行
+func (c Counts) Done() int {
+	return c.Success + c.Failed() + c.Cancelled + c.Skipped
+}
+
+// 日志中使用的统计描述，失败只列出非零的分类
+func (c Counts) String() string {
+	var details []string
+	for _, item := range []struct {
+		name  string
+		count int
+	}{
+		{"client error", c.ClientError},
+		{"server error", c.ServerError},
+		{"assertion failed", c.AssertFailed},
+		{"retries exhausted", c.Exhausted},
+		{"param error", c.ParamErrors},
+		{"responses differ", c.Mismatch},
+	} {
+		if item.count > 0 {
+			details = append(details, fmt.Sprintf("%s: %d", item.name, item.count))
+		}
+	}
+	failed := strconv.Itoa(c.Failed())
+	if len(details) > 0 {
+		failed += " (" + strings.Join(details, ", ") + ")"
+	}
+	return fmt.Sprintf("Success: %d, Failed: %s, Cancelled: %d, Skipped: %d", c.Success, failed, c.Cancelled, c.Skipped)
+}
+
+// Progress 执行进度，按已有最终结果的行统计
+type Progress struct {
+	Processed int     // 已有最终结果的数据行，含跳过的行
+	Total     int     // 数据行总数
+	Estimated bool    // Total 为估算值，数据文件还没有读完或统计完时按已读取的字节估算
+	TargetQPS float64 // 当前的目标QPS，按流量曲线执行时随时间变化
+	Stats     Report  // 截至目前的延迟、吞吐和错误率统计，不含各目标明细
+	Counts
+}
+
+// Sample 一个采样周期（约1秒）内的实时数据
+type Sample struct {
+	Time      time.Time
+	QPS       float64      // 实际发送的请求数/秒（含重试）
+	TargetQPS float64      // 目标QPS
+	Errors    float64      // 未判定为成功的请求数/秒
+	Latency   LatencyStats // 本周期内请求的延迟分布
+	Rows      Counts       // 本周期内各分类新增的行数
+}
+
+// Event Runner 在执行过程中发出的事件
+type Event struct {
+	Type     EventType
+	RowIndex int      // 行号（从1开始，含标题行），日志和进度事件为0
+	Message  string   // 可直接展示的日志内容
+	Progress Progress // 仅 EventProgress 有效
+	Sample   Sample   // 仅 EventSample 有效
+	Result   *Result  // 仅 EventRowSucceeded / EventRowFailed 有效
+}
+
+// EventHandler 事件处理函数，会被多个worker并发调用
+type EventHandler func(Event)
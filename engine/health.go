@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 熔断后默认的冷却时间
+const defaultBreakerCooldown = 10 * time.Second
+
+// 预检方式
+const (
+	PreflightNone = ""     // 不检查
+	PreflightTCP  = "tcp"  // 建立TCP连接
+	PreflightHTTP = "http" // 按请求地址的协议请求 ip:port + PreflightPath，收到5xx以外的响应即通过
+)
+
+// Preflights 支持的预检方式
+var Preflights = []string{PreflightNone, PreflightTCP, PreflightHTTP}
+
+// 单个目标的预检超时
+const preflightTimeout = 3 * time.Second
+
+// 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常
+	breakerOpen                         // 熔断中，冷却结束前不分配请求
+	breakerHalfOpen                     // 冷却结束，放行一个探测请求
+)
+
+// targetPool 管理本次执行的目标：按负载均衡策略分配请求，并跟踪健康状态。
+// 连续失败达到阈值后熔断，请求转到其他健康的目标，冷却结束后放行一个探测请求，成功则恢复
+type targetPool struct {
+	mu        sync.Mutex
+	targets   []*target
+	byAddr    map[string]*target
+	balancer  balancer
+	threshold int // 0 表示不熔断
+	cooldown  time.Duration
+	changed   chan struct{} // 状态变化时关闭，唤醒等待可用目标的worker
+	logf      func(format string, args ...interface{})
+}
+
+func newTargetPool(targets []*target, strategy string, threshold int, cooldown time.Duration, logf func(string, ...interface{})) *targetPool {
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	p := &targetPool{
+		targets:   targets,
+		byAddr:    make(map[string]*target, len(targets)),
+		balancer:  newBalancer(strategy, targets),
+		threshold: threshold,
+		cooldown:  cooldown,
+		changed:   make(chan struct{}),
+		logf:      logf,
+	}
+	for _, t := range targets {
+		p.byAddr[t.addr] = t
+	}
+	return p
+}
+
+// 状态变化后唤醒等待的worker，调用时需持有锁
+func (p *targetPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// 目标当前能否接收请求：熔断中的目标在冷却结束后可以接收一个探测请求。调用时需持有锁
+func usable(t *target, now time.Time) bool {
+	switch t.state {
+	case breakerOpen:
+		return !now.Before(t.retryAt)
+	case breakerHalfOpen:
+		return !t.probing
+	}
+	return true
+}
+
+// 为某行选择目标：在可用的目标中按策略选择，重试时优先选择该行还没有请求过的目标。
+// 全部熔断时等待冷却结束或其他请求恢复了某个目标
+func (p *targetPool) acquire(ctx context.Context, req pickRequest) (string, error) {
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		var candidates, untried []*target
+		for _, t := range p.targets {
+			if usable(t, now) {
+				candidates = append(candidates, t)
+				if !req.tried[t.addr] {
+					untried = append(untried, t)
+				}
+			}
+		}
+		if len(untried) > 0 {
+			candidates = untried
+		}
+		if len(candidates) > 0 {
+			t := p.balancer.pick(candidates, req)
+			if t.state != breakerClosed {
+				if t.state == breakerOpen {
+					p.logf("Target %s cooldown finished, sending probe request", t.addr)
+				}
+				t.state, t.probing = breakerHalfOpen, true
+			}
+			t.outstanding++
+			p.mu.Unlock()
+			return t.addr, nil
+		}
+
+		wait := p.cooldown
+		for _, t := range p.targets {
+			if t.state == breakerOpen && t.retryAt.Sub(now) < wait {
+				wait = t.retryAt.Sub(now)
+			}
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// 上报一次请求的结果。healthy 表示目标正常响应（业务失败也算正常），
+// 未收到响应或判定为重试时为 false
+func (p *targetPool) report(addr string, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.byAddr[addr]
+	t.outstanding--
+	if p.threshold <= 0 {
+		return
+	}
+	if healthy {
+		if t.state != breakerClosed {
+			p.logf("Target %s recovered, circuit closed", addr)
+			p.notify()
+		}
+		t.state, t.failures, t.probing = breakerClosed, 0, false
+		return
+	}
+
+	t.failures++
+	switch {
+	case t.state == breakerHalfOpen:
+		t.state, t.probing, t.retryAt = breakerOpen, false, time.Now().Add(p.cooldown)
+		p.logf("Target %s probe failed, circuit open for %v", addr, p.cooldown)
+		p.notify()
+	case t.state == breakerClosed && t.failures >= p.threshold:
+		t.state, t.retryAt = breakerOpen, time.Now().Add(p.cooldown)
+		p.logf("Target %s circuit open after %d consecutive failures, traffic moved to other targets for %v", addr, t.failures, p.cooldown)
+		p.notify()
+	}
+}
+
+// 请求未发出（参数错误或执行被取消）时调用，归还在途计数和半开状态的探测名额
+func (p *targetPool) release(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.byAddr[addr]
+	t.outstanding--
+	if t.state == breakerHalfOpen && t.probing {
+		t.probing = false
+		p.notify()
+	}
+}
+
+// TargetCheck 单个目标的预检结果
+type TargetCheck struct {
+	Target  string
+	OK      bool
+	Latency time.Duration
+	Error   string
+}
+
+func (c TargetCheck) String() string {
+	if c.OK {
+		return fmt.Sprintf("%s OK (%v)", c.Target, c.Latency.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s FAILED: %s", c.Target, c.Error)
+}
+
+// CheckTargets 按配置的预检方式并发检查IP列表中的每个目标，method 为空时使用TCP。
+// scheme 为 HTTP 预检使用的协议（http 或 https），一般取自 Config.TargetScheme
+func CheckTargets(ctx context.Context, ipList []string, method, scheme, path string) []TargetCheck {
+	targets := targetAddrs(ipList)
+	checks := make([]TargetCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			checks[i] = checkTarget(ctx, target, method, scheme, path)
+		}(i, target)
+	}
+	wg.Wait()
+	return checks
+}
+
+func checkTarget(ctx context.Context, target, method, scheme, path string) TargetCheck {
+	check := TargetCheck{Target: target}
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+	start := time.Now()
+
+	if method == PreflightHTTP {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+target+path, nil)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		resp.Body.Close()
+		check.Latency = time.Since(start)
+		if resp.StatusCode >= 500 {
+			check.Error = fmt.Sprintf("status %d", resp.StatusCode)
+			return check
+		}
+		check.OK = true
+		return check
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	conn.Close()
+	check.Latency = time.Since(start)
+	check.OK = true
+	return check
+}
+
+// 执行前预检，返回通过检查的目标，全部未通过时返回错误
+func (r *Runner) preflight(ctx context.Context, targets []*target) ([]*target, error) {
+	r.logf("Preflight checking %d targets (%s)", len(targets), r.config.Preflight)
+	addrs := make([]string, len(targets))
+	for i, t := range targets {
+		addrs[i] = t.addr
+	}
+	var healthy []*target
+	for i, check := range CheckTargets(ctx, addrs, r.config.Preflight, r.config.TargetScheme(), r.config.PreflightPath) {
+		r.logf("Preflight %s", check)
+		if check.OK {
+			healthy = append(healthy, targets[i])
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("所有目标预检均未通过")
+	}
+	if skipped := len(targets) - len(healthy); skipped > 0 {
+		r.logf("Preflight excluded %d unreachable targets, running with %d", skipped, len(healthy))
+	}
+	return healthy, nil
+}
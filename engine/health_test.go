@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testPool(t *testing.T, strategy string, threshold int, ipList ...string) *targetPool {
+	t.Helper()
+	targets, err := parseTargets(ipList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTargetPool(targets, strategy, threshold, time.Hour, func(string, ...interface{}) {})
+}
+
+// 连续失败达到阈值后熔断，请求转到其他目标
+func TestCircuitBreaker(t *testing.T) {
+	pool := testPool(t, "", 2, "a:1", "b:1")
+	ctx := context.Background()
+	// a:1 每次都失败，b:1 正常
+	for i := 0; i < 4; i++ {
+		addr, err := pool.acquire(ctx, pickRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.report(addr, addr != "a:1")
+	}
+	for i := 0; i < 5; i++ {
+		addr, err := pool.acquire(ctx, pickRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != "b:1" {
+			t.Fatalf("acquired %s while a:1 is open", addr)
+		}
+		pool.report(addr, true)
+	}
+
+	// 全部熔断时等待，取消后返回错误
+	pool = testPool(t, "", 1, "a:1")
+	addr, _ := pool.acquire(ctx, pickRequest{})
+	pool.report(addr, false)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := pool.acquire(cancelled, pickRequest{}); err == nil {
+		t.Error("acquire returned a target while all targets are open")
+	}
+}
+
+// 冷却结束后只放行一个探测请求，探测成功则恢复
+func TestCircuitBreakerProbe(t *testing.T) {
+	targets, _ := parseTargets([]string{"a:1"})
+	pool := newTargetPool(targets, "", 1, 20*time.Millisecond, func(string, ...interface{}) {})
+	ctx := context.Background()
+	addr, _ := pool.acquire(ctx, pickRequest{})
+	pool.report(addr, false)
+
+	start := time.Now()
+	probe, err := pool.acquire(ctx, pickRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 15*time.Millisecond {
+		t.Error("probe sent before the cooldown finished")
+	}
+	// 探测请求在途时其他请求等待
+	waiting, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(waiting, pickRequest{}); err == nil {
+		t.Error("second request acquired while the probe is in flight")
+	}
+	pool.report(probe, true)
+	if _, err := pool.acquire(ctx, pickRequest{}); err != nil {
+		t.Errorf("target not recovered after a successful probe: %v", err)
+	}
+}
+
+// HTTP 预检使用请求地址的协议，地址以变量开头时按变量的值判断
+func TestTargetScheme(t *testing.T) {
+	envs := []Environment{{Name: "prod", Vars: map[string]string{"base": "HTTPS://gw.example.com"}}}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://${ip}/api", "http"},
+		{"https://${ip}/api", "https"},
+		{"${var:base}/orders", "https"},
+		{"${var:missing}/orders", "http"},
+		{"", "http"},
+	}
+	for _, tt := range tests {
+		config := &Config{URL: tt.url, Environments: envs, Environment: "prod"}
+		if got := config.TargetScheme(); got != tt.want {
+			t.Errorf("TargetScheme(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
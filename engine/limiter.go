@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter 按固定间隔发放请求许可，速率可在执行中调整
+type rateLimiter struct {
+	mu       sync.Mutex
+	qps      float64
+	interval time.Duration
+	next     time.Time     // 下一个可用许可的时间
+	last     time.Time     // 最近一次发放许可的时间
+	changed  chan struct{} // 速率变化时关闭，通知等待中的worker重新排队
+}
+
+func newRateLimiter(qps float64) *rateLimiter {
+	l := &rateLimiter{changed: make(chan struct{})}
+	l.setRate(qps)
+	return l
+}
+
+// 调整速率。已排队的许可作废，从最近一次发放许可起按新间隔重新排队，
+// 既不会因降速前的排队拖慢提速，也不会在频繁调整时突发
+func (l *rateLimiter) setRate(qps float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if qps == l.qps {
+		return
+	}
+	l.qps = qps
+	l.interval = time.Duration(float64(time.Second) / qps)
+	l.next = l.last.Add(l.interval)
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// 当前速率
+func (l *rateLimiter) rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.qps
+}
+
+// 等待下一个许可，ctx 取消时返回错误
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if l.next.Before(now) {
+			l.next = now
+		}
+		slot := l.next
+		l.next = l.next.Add(l.interval)
+		changed := l.changed
+		l.mu.Unlock()
+
+		if delay := slot.Sub(now); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-changed:
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+		}
+
+		l.mu.Lock()
+		if slot.After(l.last) {
+			l.last = slot
+		}
+		l.mu.Unlock()
+		return nil
+	}
+}
@@ -0,0 +1,330 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columnMapping 已解析出列索引的参数映射
+type columnMapping struct {
+	ParamMapping
+	column int           // CSV列索引
+	path   []interface{} // JSONL 数据中字段的JSON路径
+}
+
+// 单列的行按制表符拆分，兼容从表格直接粘贴的数据
+func splitRow(row []string) []string {
+	if len(row) == 1 {
+		return strings.Split(row[0], "\t")
+	}
+	return row
+}
+
+// 列名统一去空白并转小写，实现不区分大小写的匹配
+func normalizeColumnName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// 建立列名到列索引的映射，重名列以第一次出现为准
+func columnIndex(header []string) map[string]int {
+	header = splitRow(header)
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		key := normalizeColumnName(name)
+		if _, exists := columns[key]; !exists {
+			columns[key] = i
+		}
+	}
+	return columns
+}
+
+// 根据标题行把参数映射中的列名或列索引解析为列索引，引用不存在的列名或超出标题行的索引时返回错误。
+// JSONL 数据的列为JSON路径，如 $.order.id，纯数字表示数组下标
+func resolveMappings(header []string, mappings []ParamMapping, format string) ([]columnMapping, error) {
+	columns := columnIndex(header)
+
+	resolved := make([]columnMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		column := strings.TrimSpace(mapping.CSVColumn)
+		if format == InputFormatJSONL {
+			path, err := parseMappingPath(column)
+			if err != nil {
+				return nil, fmt.Errorf("参数映射 [%s] 的JSON路径 %q 格式错误: %v", mapping.ParamName, column, err)
+			}
+			resolved = append(resolved, columnMapping{ParamMapping: mapping, path: path})
+			continue
+		}
+		index, err := strconv.Atoi(column)
+		if err != nil {
+			var ok bool
+			if index, ok = columns[normalizeColumnName(column)]; !ok {
+				return nil, fmt.Errorf("参数映射 [%s] 引用的列 %q 不存在，可用列: %s",
+					mapping.ParamName, column, strings.Join(splitRow(header), ", "))
+			}
+		} else if width := len(splitRow(header)); index < 0 || index >= width {
+			return nil, fmt.Errorf("参数映射 [%s] 的列索引 %d 超出范围，标题行共 %d 列（索引从0开始）",
+				mapping.ParamName, index, width)
+		}
+		resolved = append(resolved, columnMapping{ParamMapping: mapping, column: index})
+	}
+	return resolved, nil
+}
+
+// 新的参数生成函数，支持配置化映射。单个参数映射出错时记录日志并跳过该参数
+func (r *Runner) genParams(rows []string) ([]byte, error) {
+	params, mappingErrors, err := r.buildParams(rows)
+	for _, mappingErr := range mappingErrors {
+		r.logf("参数映射错误 %s", mappingErr)
+	}
+	return params, err
+}
+
+// 生成参数，同时返回被跳过的参数映射错误
+func (r *Runner) buildParams(rows []string) ([]byte, []string, error) {
+	if r.config.SourceFormat() == InputFormatJSONL {
+		return r.buildJSONLParams(rows)
+	}
+	rows = splitRow(rows)
+
+	// 获取参数映射配置
+	mappings := r.mappings
+	if len(mappings) == 0 {
+		// 如果没有配置映射，使用原来的逻辑作为兼容
+		params, err := genParamsLegacy(rows)
+		return params, nil, err
+	}
+
+	extract := func(mapping columnMapping) (interface{}, error) {
+		return extractValueFromCSV(rows, mapping)
+	}
+	// 根据参数模式生成不同格式的参数
+	if r.config.ParamMode == ParamModeArray {
+		return r.genParamsArray(mappings, extract)
+	} else {
+		return r.genParamsObject(mappings, extract)
+	}
+}
+
+// JSONL 数据按JSON路径取值生成参数，透传模式直接使用该行
+func (r *Runner) buildJSONLParams(row []string) ([]byte, []string, error) {
+	root, err := decodeJSONLine(row)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.config.ParamMode == ParamModePassthrough {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(row[0])); err != nil {
+			return nil, nil, err
+		}
+		return buf.Bytes(), nil, nil
+	}
+	if len(r.mappings) == 0 {
+		return nil, nil, fmt.Errorf("JSONL 数据需要配置参数映射或使用透传模式")
+	}
+
+	extract := func(mapping columnMapping) (interface{}, error) {
+		return extractValueFromJSON(root, mapping)
+	}
+	if r.config.ParamMode == ParamModeArray {
+		return r.genParamsArray(r.mappings, extract)
+	}
+	return r.genParamsObject(r.mappings, extract)
+}
+
+// 生成对象格式参数
+func (r *Runner) genParamsObject(mappings []columnMapping, extract func(columnMapping) (interface{}, error)) ([]byte, []string, error) {
+	params := make(map[string]interface{})
+	var mappingErrors []string
+
+	for _, mapping := range mappings {
+		value, err := extract(mapping)
+		if err != nil {
+			mappingErrors = append(mappingErrors, fmt.Sprintf("[%s]: %v", mapping.ParamName, err))
+			continue
+		}
+		params[mapping.ParamName] = value
+	}
+
+	data, err := json.Marshal(params)
+	return data, mappingErrors, err
+}
+
+// 生成数组格式参数，mappings 已在解析时按数组索引排序
+func (r *Runner) genParamsArray(mappings []columnMapping, extract func(columnMapping) (interface{}, error)) ([]byte, []string, error) {
+	// 创建紧凑的数组，按顺序填充参数
+	var params []interface{}
+	var mappingErrors []string
+
+	// 填充数组参数
+	for _, mapping := range mappings {
+		value, err := extract(mapping)
+		if err != nil {
+			mappingErrors = append(mappingErrors, fmt.Sprintf("[索引%d]: %v", mapping.ArrayIndex, err))
+			continue
+		}
+		params = append(params, value)
+	}
+
+	data, err := json.Marshal(params)
+	return data, mappingErrors, err
+}
+
+// 兼容原有逻辑的函数
+func genParamsLegacy(rows []string) ([]byte, error) {
+	if len(rows) < 5 {
+		return nil, fmt.Errorf("CSV行数据不足")
+	}
+
+	req := []interface{}{
+		rows[4], // settleOrderId
+		0,       // orderId - 根据需要调整
+	}
+
+	if len(rows) > 2 {
+		if orderId, err := strconv.Atoi(rows[2]); err == nil {
+			req[1] = orderId
+		}
+	}
+
+	return json.Marshal(req)
+}
+
+// 从CSV行中提取值并转换类型
+func extractValueFromCSV(rows []string, mapping columnMapping) (interface{}, error) {
+	var rawValue string
+
+	// 列索引超出当前行范围时使用默认值
+	if mapping.column >= 0 && mapping.column < len(rows) {
+		rawValue = rows[mapping.column]
+	} else {
+		rawValue = mapping.DefaultValue
+	}
+
+	// 如果值为空，使用默认值
+	if strings.TrimSpace(rawValue) == "" {
+		rawValue = mapping.DefaultValue
+	}
+
+	// 根据类型转换值
+	return convertValueByType(rawValue, mapping.ParamType)
+}
+
+// 从JSONL行中按JSON路径取值并转换类型，字段不存在或为 null 时使用默认值
+func extractValueFromJSON(root interface{}, mapping columnMapping) (interface{}, error) {
+	value, found := lookupJSONValue(root, mapping.path)
+	if !found || value == nil {
+		return convertValueByType(mapping.DefaultValue, mapping.ParamType)
+	}
+	switch mapping.ParamType {
+	case "json":
+		return value, nil
+	case "string[]", "int[]":
+		// JSON数组转为逗号分隔的列表再按类型转换
+		if array, ok := value.([]interface{}); ok {
+			items := make([]string, len(array))
+			for i, item := range array {
+				items[i] = jsonText(item)
+			}
+			return convertValueByType(strings.Join(items, ","), mapping.ParamType)
+		}
+	}
+	text := jsonText(value)
+	if strings.TrimSpace(text) == "" {
+		text = mapping.DefaultValue
+	}
+	return convertValueByType(text, mapping.ParamType)
+}
+
+// 根据类型转换值
+func convertValueByType(value, paramType string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+
+	switch paramType {
+	case "json":
+		// 保持原始JSON值（对象、数组、数字等）
+		if value == "" {
+			return nil, nil
+		}
+		decoder := json.NewDecoder(strings.NewReader(value))
+		decoder.UseNumber()
+		var parsed interface{}
+		if err := decoder.Decode(&parsed); err != nil {
+			return nil, fmt.Errorf("不是有效的JSON: %v", err)
+		}
+		return parsed, nil
+	case "string":
+		return value, nil
+	case "int":
+		if value == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(value)
+	case "float":
+		if value == "" {
+			return 0.0, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		if value == "" {
+			return false, nil
+		}
+		return strconv.ParseBool(value)
+	case "string[]":
+		if value == "" {
+			return []string{}, nil
+		}
+		// 支持多种分隔符：逗号、分号、竖线、空格
+		separators := []string{",", ";", "|", " "}
+		var parts []string
+		for _, sep := range separators {
+			if strings.Contains(value, sep) {
+				parts = strings.Split(value, sep)
+				break
+			}
+		}
+		if len(parts) == 0 {
+			parts = []string{value}
+		}
+		// 清理空白字符
+		var result []string
+		for _, part := range parts {
+			trimmed := strings.TrimSpace(part)
+			if trimmed != "" {
+				result = append(result, trimmed)
+			}
+		}
+		return result, nil
+	case "int[]":
+		if value == "" {
+			return []int{}, nil
+		}
+		// 支持多种分隔符：逗号、分号、竖线、空格
+		separators := []string{",", ";", "|", " "}
+		var parts []string
+		for _, sep := range separators {
+			if strings.Contains(value, sep) {
+				parts = strings.Split(value, sep)
+				break
+			}
+		}
+		if len(parts) == 0 {
+			parts = []string{value}
+		}
+		// 转换为整数
+		var result []int
+		for _, part := range parts {
+			trimmed := strings.TrimSpace(part)
+			if trimmed != "" {
+				if num, err := strconv.Atoi(trimmed); err == nil {
+					result = append(result, num)
+				}
+			}
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
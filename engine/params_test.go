@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveMappings(t *testing.T) {
+	header := []string{"orderId", "Name", "amount"}
+	tests := []struct {
+		column  string
+		want    int
+		wantErr string
+	}{
+		{"orderId", 0, ""},
+		{" name ", 1, ""},
+		{"2", 2, ""},
+		{"3", 0, "[p] 的列索引 3 超出范围"},
+		{"-1", 0, "[p] 的列索引 -1 超出范围"},
+		{"missing", 0, "[p] 引用的列 \"missing\" 不存在"},
+	}
+	for _, tt := range tests {
+		mappings, err := resolveMappings(header, []ParamMapping{{CSVColumn: tt.column, ParamName: "p"}}, InputFormatCSV)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveMappings(%q) error = %v, want %q", tt.column, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveMappings(%q): %v", tt.column, err)
+			continue
+		}
+		if mappings[0].column != tt.want {
+			t.Errorf("resolveMappings(%q) column = %d, want %d", tt.column, mappings[0].column, tt.want)
+		}
+	}
+}
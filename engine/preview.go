@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PreviewRequest 按配置为一行数据生成的请求，仅用于预览，不会发送。
+// 来自保险库的值（含引用了密钥的环境变量）显示为 ******
+type PreviewRequest struct {
+	RowIndex      int      // 行号（从1开始，含标题行），与执行日志一致
+	Row           []string // 原始CSV行
+	Target        string   // 目标 ip:port，按负载均衡策略模拟分配
+	Method        string
+	URL           string
+	Host          string   // 请求头中的 Host，未自定义时为URL中的主机
+	Headers       []Header // 按名称排序
+	Body          string
+	Params        string   // 生成的参数JSON
+	MappingErrors []string // 被跳过的参数映射错误，执行时只记录日志
+	Error         string   // 参数生成或模板渲染失败，执行时该行会直接失败
+}
+
+// HasProblem 该行是否有需要注意的错误
+func (p PreviewRequest) HasProblem() bool {
+	return p.Error != "" || len(p.MappingErrors) > 0
+}
+
+// Text 以HTTP报文的形式展示请求
+func (p PreviewRequest) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\n", p.Method, p.URL)
+	fmt.Fprintf(&sb, "Host: %s\n", p.Host)
+	for _, header := range p.Headers {
+		fmt.Fprintf(&sb, "%s: %s\n", header.Name, header.Value)
+	}
+	if p.Body != "" {
+		fmt.Fprintf(&sb, "\n%s\n", p.Body)
+	}
+	return sb.String()
+}
+
+// ParseRowRange 解析预览的行范围，数据行从1开始编号（不含标题行）：
+// "10" 表示前10行，"20-30" 表示第20到30行
+func ParseRowRange(text string) (from, to int, err error) {
+	text = strings.TrimSpace(text)
+	first, last, isRange := strings.Cut(text, "-")
+	if !isRange {
+		n, err := strconv.Atoi(text)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("预览行数必须是大于0的整数或 \"起始-结束\" 范围: %s", text)
+		}
+		return 1, n, nil
+	}
+	from, err = strconv.Atoi(strings.TrimSpace(first))
+	if err == nil {
+		to, err = strconv.Atoi(strings.TrimSpace(last))
+	}
+	if err != nil || from <= 0 || to < from {
+		return 0, 0, fmt.Errorf("预览行范围格式错误，应为 \"起始-结束\" 且起始不大于结束: %s", text)
+	}
+	return from, to, nil
+}
+
+// Preview 按配置为第 from 到 to 个数据行生成请求，不进行任何网络请求。
+// 配置或标题行有误时返回错误，单行的错误记录在对应的 PreviewRequest 中
+func Preview(config *Config, src Source, from, to int) ([]PreviewRequest, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	header, err := src.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("数据文件为空")
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := NewRunner(config, nil)
+	if err := r.prepare(header); err != nil {
+		return nil, err
+	}
+	// 按配置的负载均衡策略依次模拟分配目标，不考虑执行时的熔断和在途请求
+	targets, err := config.targets()
+	if err != nil {
+		return nil, err
+	}
+	pool := newTargetPool(targets, config.Balance, 0, 0, r.logf)
+
+	var previews []PreviewRequest
+	rowIndex := 1
+	for n := 1; n <= to; n++ {
+		row, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return previews, err
+		}
+		rowIndex = nextRowIndex(src, rowIndex)
+		if n < from {
+			continue
+		}
+		previews = append(previews, r.preview(row, rowIndex, pool))
+	}
+	return previews, nil
+}
+
+// 生成单行的预览，与执行时使用相同的参数生成和模板渲染逻辑
+func (r *Runner) preview(row []string, rowIndex int, pool *targetPool) PreviewRequest {
+	p := PreviewRequest{
+		RowIndex: rowIndex,
+		Row:      row,
+		Method:   r.config.RequestMethod(),
+	}
+	p.Target, _ = pool.acquire(context.Background(), pickRequest{rowIndex: rowIndex, key: r.balanceKey(row)})
+	pool.release(p.Target)
+	params, mappingErrors, err := r.buildParams(row)
+	p.MappingErrors = mappingErrors
+	if err != nil {
+		p.Error = fmt.Sprintf("param generation failed: %v", err)
+		return p
+	}
+	p.Params = string(params)
+
+	req, err := r.newRequest(context.Background(), &templateVars{
+		row:      splitRow(row),
+		rowIndex: rowIndex,
+		params:   params,
+		ip:       p.Target,
+		mask:     true,
+	})
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.URL = req.URL.String()
+	p.Host = req.Host
+	if p.Host == "" {
+		p.Host = req.URL.Host
+	}
+	for name, values := range req.Header {
+		for _, value := range values {
+			p.Headers = append(p.Headers, Header{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(p.Headers, func(i, k int) bool {
+		return p.Headers[i].Name < p.Headers[k].Name
+	})
+	if req.Body != nil {
+		body, _ := io.ReadAll(req.Body)
+		p.Body = string(body)
+	}
+	return p
+}
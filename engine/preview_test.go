@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// 预览中不显示保险库中的值，包括通过环境变量引用的密钥
+func TestPreviewMasksSecrets(t *testing.T) {
+	config := &Config{
+		URL:         "http://${ip}/api/${secret:path}?token=${secret:token}&id=${col:id}",
+		Cookie:      "${secret:cookie}",
+		BodyTemp:    `{"id":"${col:id}","password":"${var:password}","env":"${var:name}"}`,
+		Headers:     []Header{{Name: "Authorization", Value: "Bearer ${secret:token}"}},
+		IPList:      []string{"127.0.0.1:80"},
+		QPS:         1,
+		Workers:     1,
+		MaxRetries:  1,
+		ParamMode:   ParamModeObject,
+		Environment: "prod",
+		Environments: []Environment{
+			{Name: "prod", Vars: map[string]string{"password": "${secret:password}", "name": "prod"}},
+		},
+		ParamMappings: []ParamMapping{{CSVColumn: "id", ParamName: "id", ParamType: "string"}},
+		Secrets: map[string]string{
+			"path":     "p/q",
+			"token":    "tok&en",
+			"cookie":   "sid=s3cr3t",
+			"password": "hunter2",
+		},
+	}
+	previews, err := Preview(config, NewCSVSource(strings.NewReader("id\n7\n")), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previews) != 1 || previews[0].Error != "" {
+		t.Fatalf("previews = %+v", previews)
+	}
+	text := previews[0].Text()
+	for _, secret := range []string{"p/q", "p%2Fq", "tok&en", "tok%26en", "s3cr3t", "hunter2"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("preview contains secret %q:\n%s", secret, text)
+		}
+	}
+	for _, want := range []string{
+		"/api/******?token=******&id=7",
+		"Authorization: Bearer ******",
+		"Cookie: ******",
+		`"password":"******"`,
+		`"env":"prod"`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("preview missing %q:\n%s", want, text)
+		}
+	}
+}
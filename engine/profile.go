@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 流量曲线的最低速率，避免从0开始爬升时间隔无穷大
+const minProfileQPS = 0.1
+
+// 流量曲线调整速率的间隔
+const profileTick = 200 * time.Millisecond
+
+// Stage 流量曲线的一个阶段：在 Duration 秒内从 From 线性变化到 To，两者相同时为保持
+type Stage struct {
+	From     float64 `json:"from"`
+	To       float64 `json:"to"`
+	Duration int     `json:"duration"` // 秒
+}
+
+func (s Stage) String() string {
+	if s.From == s.To {
+		return fmt.Sprintf("%s %d", formatQPS(s.From), s.Duration)
+	}
+	return fmt.Sprintf("%s-%s %d", formatQPS(s.From), formatQPS(s.To), s.Duration)
+}
+
+func formatQPS(qps float64) string {
+	return strconv.FormatFloat(qps, 'f', -1, 64)
+}
+
+// ParseStages 解析流量曲线文本，每行一个阶段：
+// "5-50 60" 表示60秒内从5线性爬升到50，"50 120" 表示保持50持续120秒。空行和 # 开头的行忽略
+func ParseStages(text string) ([]Stage, error) {
+	var stages []Stage
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("流量曲线第%d行格式错误，应为 \"起始-目标 秒数\" 或 \"QPS 秒数\": %s", i+1, line)
+		}
+		var stage Stage
+		from, to, isRamp := strings.Cut(fields[0], "-")
+		var err error
+		if stage.From, err = strconv.ParseFloat(from, 64); err != nil {
+			return nil, fmt.Errorf("流量曲线第%d行QPS格式错误: %s", i+1, fields[0])
+		}
+		stage.To = stage.From
+		if isRamp {
+			if stage.To, err = strconv.ParseFloat(to, 64); err != nil {
+				return nil, fmt.Errorf("流量曲线第%d行QPS格式错误: %s", i+1, fields[0])
+			}
+		}
+		if stage.Duration, err = strconv.Atoi(strings.TrimSuffix(fields[1], "s")); err != nil {
+			return nil, fmt.Errorf("流量曲线第%d行秒数格式错误: %s", i+1, fields[1])
+		}
+		stages = append(stages, stage)
+	}
+	return stages, validateStages(stages)
+}
+
+// FormatStages 把流量曲线转为 ParseStages 使用的文本
+func FormatStages(stages []Stage) string {
+	lines := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		lines = append(lines, stage.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func validateStages(stages []Stage) error {
+	for i, stage := range stages {
+		if stage.From < 0 || stage.To < 0 {
+			return fmt.Errorf("流量曲线第%d个阶段的QPS不能为负数", i+1)
+		}
+		if stage.Duration <= 0 {
+			return fmt.Errorf("流量曲线第%d个阶段的持续时间必须大于0", i+1)
+		}
+	}
+	return nil
+}
+
+// profileRate 返回执行 elapsed 时间后的目标速率，所有阶段结束后返回 ok=false，改用配置的QPS
+func profileRate(stages []Stage, elapsed time.Duration) (float64, bool) {
+	for _, stage := range stages {
+		duration := time.Duration(stage.Duration) * time.Second
+		if elapsed < duration {
+			progress := float64(elapsed) / float64(duration)
+			rate := stage.From + (stage.To-stage.From)*progress
+			if rate < minProfileQPS {
+				rate = minProfileQPS
+			}
+			return rate, true
+		}
+		elapsed -= duration
+	}
+	return 0, false
+}
+
+// 按流量曲线调整限流器速率，曲线结束或 stop 关闭时返回
+func (r *Runner) followProfile(stages []Stage, start time.Time, stop <-chan struct{}) {
+	ticker := time.NewTicker(profileTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		r.controlMu.Lock()
+		if r.limiter == nil || !r.profileActive {
+			r.controlMu.Unlock()
+			return
+		}
+		rate, ok := profileRate(stages, time.Since(start))
+		if !ok {
+			r.profileActive = false
+			qps := r.qps
+			r.limiter.setRate(float64(qps))
+			r.controlMu.Unlock()
+			r.logf("Traffic profile finished, continuing at %d QPS", qps)
+			return
+		}
+		r.limiter.setRate(rate)
+		r.controlMu.Unlock()
+	}
+}
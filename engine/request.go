@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 自定义HTTP客户端，带连接池和超时设置
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second, // 请求总超时
+	Transport: &http.Transport{
+		MaxIdleConns:        100,              // 最大空闲连接数
+		MaxIdleConnsPerHost: 20,               // 每个主机的最大空闲连接数
+		MaxConnsPerHost:     50,               // 每个主机的最大连接数
+		IdleConnTimeout:     90 * time.Second, // 空闲连接超时
+		TLSHandshakeTimeout: 10 * time.Second, // TLS握手超时
+		DisableCompression:  false,            // 启用压缩
+		ForceAttemptHTTP2:   true,             // 尝试使用HTTP/2
+		DisableKeepAlives:   false,            // 启用连接复用
+	},
+}
+
+// 读取响应体的大小上限，避免内存问题
+const maxResponseSize = 5 * 1024 * 1024 // 5MB限制，减少内存使用
+
+// 第 retryCount 次重试前的退避时间，最长5秒
+func retryBackoff(retryCount int) time.Duration {
+	backoffDelay := time.Duration(retryCount*retryCount*100) * time.Millisecond
+	if backoffDelay > 5*time.Second {
+		backoffDelay = 5 * time.Second
+	}
+	return backoffDelay
+}
+
+// 等待第 retryCount 次重试前的退避时间，执行被停止时立即返回
+func waitBackoff(ctx context.Context, retryCount int) {
+	timer := time.NewTimer(retryBackoff(retryCount))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// 发送单行请求，返回该行的最终结果和对应的日志内容
+func (r *Runner) sendRequest(ctx context.Context, task RequestTask, maxRetries int) (*Result, string) {
+	retryCount := 0
+	startTime := time.Now()
+	result := &Result{
+		RowIndex: task.RowIndex,
+		Row:      task.Row,
+		Params:   string(task.ParamsJSON),
+	}
+	defer func() {
+		result.ElapsedMs = durationMs(time.Since(startTime))
+	}()
+
+	vars := &templateVars{
+		row:      splitRow(task.Row),
+		rowIndex: task.RowIndex,
+		params:   task.ParamsJSON,
+	}
+	pick := pickRequest{rowIndex: task.RowIndex, key: r.balanceKey(task.Row), tried: make(map[string]bool)}
+
+	for retryCount < maxRetries {
+		// 为每次重试添加指数退避延迟，避免惊群效应
+		if retryCount > 0 {
+			waitBackoff(ctx, retryCount)
+		}
+		select {
+		case <-ctx.Done():
+			result.Outcome = ResultCancelled
+			result.Error = ctx.Err().Error()
+			return result, fmt.Sprintf("Row %d cancelled", task.RowIndex)
+		default:
+		}
+		result.Retries = retryCount
+
+		// 按负载均衡策略选择IP，跳过熔断中的目标，重试时优先换一个目标
+		randomIP, err := r.targets.acquire(ctx, pick)
+		if err != nil {
+			result.Outcome = ResultCancelled
+			result.Error = err.Error()
+			return result, fmt.Sprintf("Row %d cancelled", task.RowIndex)
+		}
+		pick.tried[randomIP] = true
+		result.Target = randomIP
+		vars.ip = randomIP
+
+		// 创建带超时的子上下文
+		reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+
+		// 按编译好的模板生成请求
+		req, err := r.newRequest(reqCtx, vars)
+		if err != nil {
+			cancel()
+			r.targets.release(randomIP)
+			result.Outcome = ResultParamError
+			result.Error = err.Error()
+			return result, fmt.Sprintf("Row %d %v", task.RowIndex, err)
+		}
+
+		// 发送请求 - 使用优化的HTTP客户端
+		requestStart := time.Now()
+		resp, err := r.client.Do(req)
+		requestDuration := time.Since(requestStart)
+		result.LatencyMs = durationMs(requestDuration)
+
+		if err != nil {
+			cancel()
+			// 执行被停止导致的失败不计入重试，也不算作目标的失败
+			if ctx.Err() != nil {
+				r.targets.release(randomIP)
+				result.Outcome = ResultCancelled
+				result.Error = ctx.Err().Error()
+				return result, fmt.Sprintf("Row %d cancelled", task.RowIndex)
+			}
+			r.stats.record(randomIP, requestDuration, false, true)
+			r.targets.report(randomIP, false)
+			retryCount++
+			result.Status = 0
+			result.Error = err.Error()
+			r.rowLogf(task.RowIndex, "Row %d request failed (retry %d/%d, duration: %v): %v",
+				task.RowIndex, retryCount, maxRetries, requestDuration, err)
+			continue
+		}
+
+		// 优化响应读取 - 限制响应大小避免内存问题
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+		cancel() // 响应读取完毕后再取消上下文，释放资源
+		result.Status = resp.StatusCode
+		result.setResponse(respBody)
+
+		if err != nil {
+			r.stats.record(randomIP, requestDuration, false, true)
+			r.targets.report(randomIP, false)
+			retryCount++
+			result.Error = fmt.Sprintf("response read failed: %v", err)
+			r.rowLogf(task.RowIndex, "Row %d response read failed (retry %d/%d): %v", task.RowIndex, retryCount, maxRetries, err)
+			continue
+		}
+
+		// 按断言规则判断成功、失败或重试
+		outcome, reason := evaluateAssertions(r.assertions, r.assertDefault(), &response{
+			status:  resp.StatusCode,
+			header:  resp.Header,
+			body:    respBody,
+			latency: requestDuration,
+		})
+		r.stats.record(randomIP, requestDuration, true, outcome != OutcomeSuccess)
+		r.targets.report(randomIP, outcome != OutcomeRetry)
+		switch outcome {
+		case OutcomeRetry:
+			retryCount++
+			result.Error = reason
+			r.rowLogf(task.RowIndex, "Row %d status %d, %s (retry %d/%d)", task.RowIndex, resp.StatusCode, reason, retryCount, maxRetries)
+			continue
+		case OutcomeFail:
+			// 判定为失败的响应不重试
+			result.Outcome = failedOutcome(resp.StatusCode)
+			result.Error = reason
+			return result, fmt.Sprintf("Row %d failed with status %d, %s: %s", task.RowIndex, resp.StatusCode, reason, string(respBody))
+		}
+
+		// 记录成功响应和耗时
+		totalDuration := time.Since(startTime)
+		result.Success = true
+		result.Outcome = ResultSuccess
+		result.Error = ""
+		return result, fmt.Sprintf("Row %d success in %v (request: %v): %s",
+			task.RowIndex, totalDuration, requestDuration, string(respBody))
+	}
+
+	result.Outcome = ResultExhausted
+	return result, fmt.Sprintf("Row %d final failure after %d retries, total time: %v", task.RowIndex, maxRetries, time.Since(startTime))
+}
+
+// 按编译好的模板渲染请求地址、请求体和请求头，不发送请求
+func (r *Runner) newRequest(ctx context.Context, vars *templateVars) (*http.Request, error) {
+	target, err := r.url.render(vars)
+	if err != nil {
+		return nil, fmt.Errorf("url template render failed: %v", err)
+	}
+	var body io.Reader
+	if r.body != nil {
+		data, err := r.body.render(vars)
+		if err != nil {
+			return nil, fmt.Errorf("body template render failed: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.config.RequestMethod(), target, body)
+	if err != nil {
+		return nil, fmt.Errorf("request creation failed: %v", err)
+	}
+	if err := r.setHeaders(req, vars); err != nil {
+		return nil, fmt.Errorf("header template render failed: %v", err)
+	}
+	return req, nil
+}
+
+// 没有断言命中时的结果
+func (r *Runner) assertDefault() string {
+	if r.config.AssertDefault == "" {
+		return OutcomeSuccess
+	}
+	return r.config.AssertDefault
+}
+
+// 设置请求头：先按请求体格式设置 Content-Type，再应用自定义请求头，最后设置 Cookie
+func (r *Runner) setHeaders(req *http.Request, vars *templateVars) error {
+	if r.body != nil {
+		req.Header.Set("Content-Type", contentTypes[r.config.RequestBodyFormat()])
+	}
+
+	for _, header := range r.headers {
+		value, err := header.value.render(vars)
+		if err != nil {
+			return err
+		}
+		// Host 需要设置在请求上，放在 Header 中不会生效
+		if strings.EqualFold(header.name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(header.name, value)
+	}
+
+	if r.cookie != "" {
+		cookie := r.cookie
+		if r.cookieSecret && vars.mask {
+			cookie = secretMask
+		}
+		req.Header.Set("Cookie", cookie)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 指向测试服务器的最小配置
+func testConfig(server *httptest.Server) *Config {
+	return &Config{
+		URL:        "http://${ip}/api",
+		BodyTemp:   `{"id":"${col:id}"}`,
+		IPList:     []string{strings.TrimPrefix(server.URL, "http://")},
+		QPS:        1000,
+		Workers:    1,
+		MaxRetries: 1,
+		ParamMode:  ParamModeObject,
+		ParamMappings: []ParamMapping{
+			{CSVColumn: "id", ParamName: "id", ParamType: "string"},
+		},
+	}
+}
+
+// 最后一次尝试中被停止的请求记为取消，不算重试用尽
+func TestSendRequestCancelledOnLastAttempt(t *testing.T) {
+	arrived := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body) // 读完请求体后才能感知客户端断开
+		close(arrived)
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	r := NewRunner(testConfig(server), nil)
+	if err := r.prepare([]string{"id"}); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := r.config.targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.targets = newTargetPool(targets, "", 0, 0, r.logf)
+	r.stats = newStats()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	result, _ := r.sendRequest(ctx, RequestTask{ParamsJSON: []byte(`{"id":"1"}`), RowIndex: 2, Row: []string{"1"}}, 1)
+	if result.Outcome != ResultCancelled {
+		t.Errorf("outcome = %q, want %q", result.Outcome, ResultCancelled)
+	}
+	if result.Retries != 0 {
+		t.Errorf("retries = %d, want 0", result.Retries)
+	}
+}
+
+// 停止执行时不再等待重试的退避时间，该行记为取消
+func TestRetryBackoffCancelled(t *testing.T) {
+	task := RequestTask{ParamsJSON: []byte(`{"id":"1"}`), RowIndex: 2, Row: []string{"1"}}
+	tests := []struct {
+		name string
+		send func(ctx context.Context, r *Runner, target string) string
+	}{
+		{"request", func(ctx context.Context, r *Runner, target string) string {
+			result, _ := r.sendRequest(ctx, task, 10)
+			return result.Outcome
+		}},
+		{"compare", func(ctx context.Context, r *Runner, target string) string {
+			return r.fetch(ctx, task, target, 10).Outcome
+		}},
+	}
+	for _, tt := range tests {
+		var requests atomic.Int64
+		third := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if requests.Add(1) == 3 {
+				close(third) // 第 3 次重试前需要退避 900ms
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		r := NewRunner(testConfig(server), nil)
+		if err := r.prepare([]string{"id"}); err != nil {
+			t.Fatal(err)
+		}
+		targets, err := r.config.targets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.targets = newTargetPool(targets, "", 0, 0, r.logf)
+		r.stats = newStats()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var cancelled time.Time
+		go func() {
+			<-third
+			cancelled = time.Now()
+			cancel()
+		}()
+		outcome := tt.send(ctx, r, targets[0].addr)
+		if waited := time.Since(cancelled); waited > 500*time.Millisecond {
+			t.Errorf("%s: returned %v after cancel, want no backoff wait", tt.name, waited)
+		}
+		if outcome != ResultCancelled {
+			t.Errorf("%s: outcome = %q, want %q", tt.name, outcome, ResultCancelled)
+		}
+		cancel()
+		server.Close()
+	}
+}
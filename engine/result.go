@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 结果文件格式
+const (
+	ResultFormatCSV   = "csv"
+	ResultFormatJSONL = "jsonl"
+	ResultFormatXLSX  = "xlsx" // 把各行结果写回数据工作簿的副本，需要使用 Excel 数据文件
+)
+
+// 单行的最终结果分类
+const (
+	ResultSuccess      = "success"           // 成功
+	ResultClientError  = "client_error"      // 判定失败且状态码为4xx
+	ResultServerError  = "server_error"      // 判定失败且状态码为5xx
+	ResultAssertFailed = "assert_failed"     // 判定失败的其他响应，如200但业务失败
+	ResultExhausted    = "retries_exhausted" // 重试次数用尽仍未成功
+	ResultCancelled    = "cancelled"         // 执行被停止时请求未完成
+	ResultParamError   = "param_error"       // 参数生成或模板渲染失败，未发送请求
+	ResultMismatch     = "mismatch"          // 对比模式下各目标的响应不一致
+)
+
+// 判定失败时按状态码区分客户端错误、服务端错误和断言失败
+func failedOutcome(status int) string {
+	switch {
+	case status >= 500:
+		return ResultServerError
+	case status >= 400:
+		return ResultClientError
+	default:
+		return ResultAssertFailed
+	}
+}
+
+// 结果文件中响应体的最大长度，超出部分截断
+const maxResultResponseSize = 8 * 1024
+
+// Result 单行的最终执行结果
+type Result struct {
+	RowIndex  int      `json:"rowIndex"`            // 行号（从1开始，含标题行）
+	Row       []string `json:"row"`                 // 原始CSV行
+	Params    string   `json:"params"`              // 生成的 ParamsJSON
+	Target    string   `json:"target"`              // 最后一次请求使用的 ip:port
+	Success   bool     `json:"success"`             // 是否最终成功
+	Outcome   string   `json:"outcome"`             // 结果分类，见 ResultSuccess 等常量
+	Status    int      `json:"status"`              // 最后一次请求的HTTP状态码，未收到响应时为0
+	Retries   int      `json:"retries"`             // 重试次数
+	LatencyMs float64  `json:"latencyMs"`           // 最后一次请求耗时
+	ElapsedMs float64  `json:"elapsedMs"`           // 含重试的总耗时
+	Error     string   `json:"error,omitempty"`     // 失败原因
+	Response  string   `json:"response"`            // 响应体（可能被截断）
+	Truncated bool     `json:"truncated,omitempty"` // 响应体是否被截断
+
+	Compare *Comparison `json:"compare,omitempty"` // 对比模式下各目标的响应和差异
+}
+
+func (res *Result) setResponse(body []byte) {
+	res.Response, res.Truncated = truncateResponse(body)
+}
+
+// 把响应体截断到 maxResultResponseSize，截断点落在多字节字符中间时退到该字符之前，
+// 避免结果中出现半个字符
+func truncateResponse(body []byte) (string, bool) {
+	if len(body) <= maxResultResponseSize {
+		return string(body), false
+	}
+	cut := maxResultResponseSize
+	for i := 1; i < utf8.UTFMax && !utf8.RuneStart(body[cut]); i++ {
+		cut--
+	}
+	return string(body[:cut]), true
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// ResultSink 逐行写出执行结果，实现需要支持并发调用
+type ResultSink interface {
+	Write(result *Result) error
+	Close() error
+}
+
+// ResultFormatFromPath 根据文件扩展名推断结果格式，无法识别时使用CSV
+func ResultFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return ResultFormatJSONL
+	case ".xlsx":
+		return ResultFormatXLSX
+	default:
+		return ResultFormatCSV
+	}
+}
+
+// OpenResultSink 创建结果文件，header 为输入数据的标题行，用于CSV格式的列名
+func OpenResultSink(path, format string, header []string) (ResultSink, error) {
+	if format == "" {
+		format = ResultFormatFromPath(path)
+	}
+	if format != ResultFormatCSV && format != ResultFormatJSONL {
+		return nil, fmt.Errorf("不支持的结果文件格式: %s", format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建结果文件失败: %v", err)
+	}
+	if format == ResultFormatJSONL {
+		return NewJSONLResultSink(file), nil
+	}
+	sink, err := NewCSVResultSink(file, header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+// 结果列，排在原始CSV列之后
+var resultColumns = []string{
+	"rowIndex", "success", "outcome", "status", "target", "retries",
+	"latencyMs", "elapsedMs", "error", "params", "response",
+}
+
+// CSVResultSink 以CSV格式写出结果：原始列 + 结果列
+type CSVResultSink struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	writer *csv.Writer
+	width  int // 原始列数，不足时补空以保证结果列对齐
+}
+
+// NewCSVResultSink 写出标题行并返回结果写入器
+func NewCSVResultSink(w io.WriteCloser, header []string) (*CSVResultSink, error) {
+	sink := &CSVResultSink{w: w, writer: csv.NewWriter(w), width: len(header)}
+	if err := sink.writer.Write(append(append([]string{}, header...), resultColumns...)); err != nil {
+		return nil, err
+	}
+	sink.writer.Flush()
+	return sink, sink.writer.Error()
+}
+
+func (s *CSVResultSink) Write(result *Result) error {
+	record := make([]string, s.width, s.width+len(resultColumns))
+	copy(record, result.Row)
+	record = append(record,
+		strconv.Itoa(result.RowIndex),
+		strconv.FormatBool(result.Success),
+		result.Outcome,
+		strconv.Itoa(result.Status),
+		result.Target,
+		strconv.Itoa(result.Retries),
+		strconv.FormatFloat(result.LatencyMs, 'f', 3, 64),
+		strconv.FormatFloat(result.ElapsedMs, 'f', 3, 64),
+		result.Error,
+		result.Params,
+		result.Response,
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writer.Write(record); err != nil {
+		return err
+	}
+	// 每行立即落盘，进程异常退出时也能保留已完成的结果
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *CSVResultSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		s.w.Close()
+		return err
+	}
+	return s.w.Close()
+}
+
+// JSONLResultSink 以JSON Lines格式写出结果，每行一个对象
+type JSONLResultSink struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewJSONLResultSink 返回JSON Lines结果写入器
+func NewJSONLResultSink(w io.WriteCloser) *JSONLResultSink {
+	return &JSONLResultSink{w: w}
+}
+
+func (s *JSONLResultSink) Write(result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *JSONLResultSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 截断响应体时不拆开多字节字符
+func TestTruncateResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantLen   int
+		truncated bool
+	}{
+		{"short", "订单", len("订单"), false},
+		{"exact", strings.Repeat("a", maxResultResponseSize), maxResultResponseSize, false},
+		{"ascii", strings.Repeat("a", maxResultResponseSize+1), maxResultResponseSize, true},
+		// 3 字节的汉字在 8192 字节处被拆开：8190 = 3 × 2730，截断点落在第 2731 个字的第 2 个字节
+		{"cjk", strings.Repeat("订", maxResultResponseSize), maxResultResponseSize - 2, true},
+		{"emoji", "a" + strings.Repeat("😀", maxResultResponseSize), maxResultResponseSize - 3, true},
+	}
+	for _, tt := range tests {
+		got, truncated := truncateResponse([]byte(tt.body))
+		if len(got) != tt.wantLen || truncated != tt.truncated {
+			t.Errorf("%s: len = %d truncated = %v, want %d %v", tt.name, len(got), truncated, tt.wantLen, tt.truncated)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: truncated response is not valid UTF-8", tt.name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// RowOutcomes 一次执行中未成功的行的最终结果分类，键为行号（从1开始，含标题行）。
+// 成功和未执行的行不在其中，数据文件很大时只占用与失败行数相关的内存
+type RowOutcomes map[int]string
+
+// Unsuccessful 未成功的行号（失败和被取消的行），按行号排序
+func (o RowOutcomes) Unsuccessful() []int {
+	var rows []int
+	for rowIndex, outcome := range o {
+		if outcome != ResultSuccess {
+			rows = append(rows, rowIndex)
+		}
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+// SetRows 只执行指定行号的数据行，用于重新执行上次失败的行。行号与执行日志一致，其余行不计入总数
+func (r *Runner) SetRows(rowIndexes []int) {
+	r.rows = make(map[int]bool, len(rowIndexes))
+	for _, rowIndex := range rowIndexes {
+		r.rows[rowIndex] = true
+	}
+}
+
+// 该行是否需要执行
+func (r *Runner) selected(rowIndex int) bool {
+	return r.rows == nil || r.rows[rowIndex]
+}
+
+// ExportRows 把数据源中指定行号的行写入新的数据文件，返回写出的数据行数。
+// CSV 保留标题行，JSONL 每行原样写出；按数据文件原来的编码写出，导出的文件可以用同一份配置执行
+func ExportRows(src Source, format, path string, rowIndexes []int) (int, error) {
+	wanted := make(map[int]bool, len(rowIndexes))
+	for _, rowIndex := range rowIndexes {
+		wanted[rowIndex] = true
+	}
+
+	name := EncodingUTF8
+	if src, ok := src.(encoded); ok {
+		name = src.dataEncoding()
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+	out, err := encodeWriter(file, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int
+	if format == InputFormatJSONL {
+		written, err = exportLines(src, out, wanted)
+	} else {
+		written, err = exportCSV(src, out, wanted)
+	}
+	if err != nil {
+		return written, err
+	}
+	if err := out.Close(); err != nil {
+		return written, err
+	}
+	return written, file.Close()
+}
+
+func exportCSV(src Source, out io.Writer, wanted map[int]bool) (int, error) {
+	writer := csv.NewWriter(out)
+	written := 0
+	for rowIndex, header := 0, true; ; header = false {
+		row, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		rowIndex = nextRowIndex(src, rowIndex)
+		if !header && !wanted[rowIndex] {
+			continue
+		}
+		if err := writer.Write(row); err != nil {
+			return written, err
+		}
+		if !header {
+			written++
+		}
+	}
+	writer.Flush()
+	return written, writer.Error()
+}
+
+// 按行号写出 JSONL 数据的原始行，跳过虚拟标题行
+func exportLines(src Source, out io.Writer, wanted map[int]bool) (int, error) {
+	writer := bufio.NewWriter(out)
+	written := 0
+	for rowIndex, header := 0, true; ; header = false {
+		row, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		rowIndex = nextRowIndex(src, rowIndex)
+		if header || !wanted[rowIndex] {
+			continue
+		}
+		if _, err := writer.WriteString(row[0] + "\n"); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, writer.Flush()
+}
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 重新执行失败的行时只发送指定的行，总数为指定的行数
+func TestRetryFailedRows(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(outcomeHandler(&requests))
+	defer server.Close()
+
+	config := testConfig(server)
+	config.URL = "http://${ip}/api?id=${col:id}"
+	r := NewRunner(config, nil)
+	r.SetRows([]int{3, 6})
+	summary, err := r.Run(context.Background(), NewCSVSource(strings.NewReader(outcomeData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 2 || summary.Success != 1 || summary.ClientError != 1 || requests.Load() != 2 {
+		t.Errorf("total %d, counts %+v, requests %d", summary.Total, summary.Counts, requests.Load())
+	}
+	if got := summary.Outcomes.Unsuccessful(); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("Unsuccessful = %v, want [3]", got)
+	}
+}
+
+// 导出失败行时保留标题行，只写出指定行号的行
+func TestExportCSVRows(t *testing.T) {
+	var out bytes.Buffer
+	written, err := exportCSV(NewCSVSource(strings.NewReader(outcomeData)), &out, map[int]bool{3: true, 5: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id\nmissing\nbusy\n"; written != 2 || out.String() != want {
+		t.Errorf("exported %d rows %q, want %q", written, out.String(), want)
+	}
+}
@@ -0,0 +1,643 @@
+package engine
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source 输入数据源，按行返回字段，读完时返回 io.EOF
+type Source interface {
+	Read() ([]string, error)
+}
+
+// NewCSVSource 从CSV内容创建数据源，第一行为标题行
+func NewCSVSource(r io.Reader) Source {
+	return csv.NewReader(r)
+}
+
+// RequestTask 请求任务结构
+type RequestTask struct {
+	ParamsJSON []byte
+	RowIndex   int
+	Row        []string // 原始数据行（JSONL 为该行原文），用于结果输出
+}
+
+// Summary 一次批量执行的结果汇总
+type Summary struct {
+	Total     int         // 数据行总数（不含标题行）
+	Estimated bool        // Total 为估算值：中途停止时数据文件还没有读完，行数也还没有统计完
+	Counts                // 按最终结果分类的行数，被取消时未派发的行不计入
+	Stopped   bool        // 是否被中途停止
+	Report    Report      // 延迟、吞吐和错误率统计报告
+	Outcomes  RowOutcomes // 未成功的行的最终结果分类，用于重新执行失败的行
+
+	// 对比模式下响应不一致的行，按行号排序，最多保留 maxMismatches 行
+	Mismatches []*Result
+}
+
+// Runner 批量请求执行器，只依赖配置和数据源，不涉及任何界面
+type Runner struct {
+	config         *Config
+	client         *http.Client
+	handler        EventHandler
+	sink           ResultSink
+	checkpoint     *Checkpoint
+	mappings       []columnMapping     // 按标题行解析后的参数映射
+	url            *urlTemplate        // 编译后的请求地址模板
+	body           bodyTemplate        // 编译后的请求体模板，为空时不发送请求体
+	headers        []headerTemplate    // 编译后的请求头
+	cookie         string              // 展开环境变量和密钥后的 Cookie
+	cookieSecret   bool                // Cookie 引用了密钥，预览时隐藏
+	assertions     []compiledAssertion // 编译后的响应断言
+	stats          *Stats              // 本次执行的请求统计
+	rows           map[int]bool        // 只执行这些行号，为空时执行全部行
+	targets        *targetPool         // 目标列表及其熔断状态
+	balanceColumn  int                 // hash 负载均衡使用的列索引，未使用时为-1
+	balancePath    []interface{}       // JSONL 数据中 hash 负载均衡使用的JSON路径
+	compareTargets []string            // 对比模式下的目标，第一个为基准
+	ignore         *ignoreRules        // 对比模式下比较响应时忽略的字段
+
+	sinkErrOnce       sync.Once
+	checkpointErrOnce sync.Once
+
+	// 执行中可调整的限流器和worker池，未在执行时为空
+	controlMu     sync.Mutex
+	limiter       *rateLimiter
+	pool          *workerPool
+	profileActive bool // 是否正在按流量曲线调整QPS，手动调整QPS后停止
+	qps           int  // 当前QPS和并发数，初始为配置的值，调整时不修改调用方的配置
+	workers       int
+
+	// 各worker上报的最终结果统计
+	countsMu     sync.Mutex
+	counts       Counts
+	outcomes     RowOutcomes
+	mismatches   []*Result
+	total        int
+	totalExact   bool // total 是否为准确值，否则为按已读取字节的估算
+	lastProgress time.Time
+}
+
+// NewRunner 创建执行器，handler 接收执行过程中的全部事件，可为空
+func NewRunner(config *Config, handler EventHandler) *Runner {
+	if handler == nil {
+		handler = func(Event) {}
+	}
+	return &Runner{
+		config:  config,
+		client:  httpClient,
+		handler: handler,
+		qps:     config.QPS,
+		workers: config.Workers,
+	}
+}
+
+// SetCheckpoint 设置断点记录，已记录为成功的行会被跳过，本次成功的行会追加记录
+func (r *Runner) SetCheckpoint(checkpoint *Checkpoint) {
+	r.checkpoint = checkpoint
+}
+
+func (r *Runner) emit(event Event) {
+	r.handler(event)
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	r.emit(Event{Type: EventLog, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Runner) rowLogf(rowIndex int, format string, args ...interface{}) {
+	r.emit(Event{Type: EventLog, RowIndex: rowIndex, Message: fmt.Sprintf(format, args...)})
+}
+
+// 记录某行的最终结果：写入结果文件并发出成功/失败事件
+func (r *Runner) finishRow(result *Result, message string) {
+	if result.Success && r.checkpoint != nil {
+		if err := r.checkpoint.MarkDone(result.RowIndex); err != nil {
+			r.checkpointErrOnce.Do(func() {
+				r.logf("Checkpoint write failed: %v", err)
+			})
+		}
+	}
+	if r.sink != nil {
+		if err := r.sink.Write(result); err != nil {
+			r.sinkErrOnce.Do(func() {
+				r.logf("Result file write failed: %v", err)
+			})
+		}
+	}
+
+	eventType := EventRowFailed
+	if result.Success {
+		eventType = EventRowSucceeded
+	}
+	r.emit(Event{Type: eventType, RowIndex: result.RowIndex, Message: message, Result: result})
+	r.record(func(c *Counts) {
+		c.add(result.Outcome)
+		if result.Outcome != ResultSuccess {
+			r.outcomes[result.RowIndex] = result.Outcome
+		}
+		if result.Outcome == ResultMismatch {
+			r.recordMismatch(result)
+		}
+	})
+}
+
+// 已派发但因执行停止未发送的任务记为取消，重新执行失败的行时会包含这些行
+func (r *Runner) cancelTask(task RequestTask, err error) {
+	result := &Result{
+		RowIndex: task.RowIndex,
+		Row:      task.Row,
+		Params:   string(task.ParamsJSON),
+		Outcome:  ResultCancelled,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	r.finishRow(result, fmt.Sprintf("Row %d cancelled", task.RowIndex))
+}
+
+// 进度事件的发送间隔：每完成一批行或距上次超过1秒
+const (
+	progressBatch    = 100
+	progressInterval = time.Second
+)
+
+// 更新结果统计，按批次或时间间隔发出进度事件
+func (r *Runner) record(update func(c *Counts)) {
+	r.countsMu.Lock()
+	update(&r.counts)
+	done := r.counts.Done()
+	now := time.Now()
+	report := done%progressBatch == 0 || done == r.total || now.Sub(r.lastProgress) >= progressInterval
+	if report {
+		r.lastProgress = now
+	}
+	counts := r.counts
+	total, estimated := r.total, !r.totalExact
+	r.countsMu.Unlock()
+
+	if report {
+		// 估算值偏小时以已完成的行数为准
+		if total < done {
+			total = done
+		}
+		r.emit(Event{
+			Type:     EventProgress,
+			Progress: Progress{Processed: done, Total: total, Estimated: estimated, TargetQPS: r.targetQPS(), Stats: r.stats.report(false), Counts: counts},
+		})
+	}
+}
+
+// 当前的结果统计
+func (r *Runner) snapshot() Counts {
+	r.countsMu.Lock()
+	defer r.countsMu.Unlock()
+	return r.counts
+}
+
+// 各行的最终结果，执行结束后调用
+func (r *Runner) rowOutcomes() RowOutcomes {
+	r.countsMu.Lock()
+	defer r.countsMu.Unlock()
+	outcomes := make(RowOutcomes, len(r.outcomes))
+	for rowIndex, outcome := range r.outcomes {
+		outcomes[rowIndex] = outcome
+	}
+	return outcomes
+}
+
+// 按标题行解析参数映射并编译模板，每次执行只做一次
+func (r *Runner) prepare(header []string) error {
+	mappings, err := resolveMappings(header, r.config.ParamMappings, r.config.SourceFormat())
+	if err != nil {
+		return err
+	}
+	if r.config.ParamMode == ParamModeArray {
+		sort.SliceStable(mappings, func(i, k int) bool {
+			return mappings[i].ArrayIndex < mappings[k].ArrayIndex
+		})
+	}
+
+	vars, err := r.config.ActiveVars()
+	if err != nil {
+		return err
+	}
+	cookie, err := expandVars(r.config.Cookie, vars)
+	if err == nil {
+		cookie, err = expandSecrets(cookie, r.config.Secrets)
+	}
+	if err != nil {
+		return fmt.Errorf("Cookie %v", err)
+	}
+	secretVars := r.config.secretVars()
+	compiler := newTemplateCompiler(header, vars, r.config.Secrets)
+	compiler.secretVars = secretVars
+	url, err := compiler.compileURL(r.config.URL)
+	if err != nil {
+		return err
+	}
+	var body bodyTemplate
+	if methodHasBody(r.config.RequestMethod()) && strings.TrimSpace(r.config.BodyTemp) != "" {
+		if body, err = compiler.compileBody(r.config.RequestBodyFormat(), r.config.BodyTemp); err != nil {
+			return err
+		}
+	}
+	headers, err := compiler.compileHeaders(r.config.Headers)
+	if err != nil {
+		return err
+	}
+	assertions, err := compileAssertions(r.config.RequestAssertions())
+	if err != nil {
+		return err
+	}
+	ignore, err := compileIgnoreRules(r.config.CompareIgnore)
+	if err != nil {
+		return err
+	}
+
+	r.balanceColumn, r.balancePath = -1, nil
+	if r.config.Balance == BalanceHash {
+		if r.config.SourceFormat() == InputFormatJSONL {
+			if r.balancePath, err = parseMappingPath(strings.TrimSpace(r.config.BalanceKey)); err != nil {
+				return fmt.Errorf("负载均衡的JSON路径 %q 格式错误: %v", r.config.BalanceKey, err)
+			}
+		} else if r.balanceColumn, err = resolveColumn(header, r.config.BalanceKey); err != nil {
+			return err
+		}
+	}
+
+	r.mappings = mappings
+	r.url = url
+	r.body = body
+	r.headers = headers
+	r.assertions = assertions
+	r.ignore = ignore
+	r.cookie = strings.TrimSpace(cookie)
+	r.cookieSecret = strings.Contains(r.config.Cookie, secretPrefix)
+	for name := range secretVars {
+		if strings.Contains(r.config.Cookie, "${var:"+name+"}") {
+			r.cookieSecret = true
+		}
+	}
+	return nil
+}
+
+// ValidateInput 按数据的标题行检查参数映射和模板，在开始执行前发现配置错误
+func ValidateInput(config *Config, header []string) error {
+	return NewRunner(config, nil).prepare(header)
+}
+
+// Run 读取数据源并按配置的QPS和并发数发送请求，直到数据读完或 ctx 被取消
+func (r *Runner) Run(ctx context.Context, src Source) (Summary, error) {
+	var summary Summary
+
+	if err := r.config.Validate(); err != nil {
+		return summary, &ConfigError{Err: err}
+	}
+
+	r.controlMu.Lock()
+	qps, workers := r.qps, r.workers
+	r.controlMu.Unlock()
+	maxRetries := r.config.MaxRetries
+	targets, err := r.config.targets()
+	if err != nil {
+		return summary, &ConfigError{Err: err}
+	}
+
+	r.logf("Starting execution - QPS: %d, Workers: %d, Retries: %d", qps, workers, maxRetries)
+
+	// 预检目标，不可达的目标不参与本次执行
+	if r.config.Preflight != PreflightNone {
+		if targets, err = r.preflight(ctx, targets); err != nil {
+			return summary, err
+		}
+	}
+	r.targets = newTargetPool(targets, r.config.Balance, r.config.BreakerThreshold,
+		time.Duration(r.config.BreakerCooldown)*time.Second, r.logf)
+
+	// 对比模式：每行发送到所有目标，不使用负载均衡和熔断
+	r.compareTargets = nil
+	if r.config.CompareMode {
+		if len(targets) < 2 {
+			return summary, fmt.Errorf("对比模式至少需要两个可用的目标")
+		}
+		for _, t := range targets {
+			r.compareTargets = append(r.compareTargets, t.addr)
+		}
+		r.logf("Compare mode: each row is sent to all %d targets, baseline %s", len(targets), targets[0].addr)
+	}
+
+	// 创建请求队列和限流器 - 优化队列大小
+	requestQueue := make(chan RequestTask, workers*2) // 根据worker数量调整队列大小
+	limiter := newRateLimiter(float64(qps))
+	if rate, ok := profileRate(r.config.Profile, 0); ok {
+		limiter.setRate(rate)
+	}
+
+	// 对比模式每行向所有目标各发送一次请求，按目标数领取许可，使 QPS 限制的是实际请求数
+	permits := 1
+	if r.compareTargets != nil {
+		permits = len(r.compareTargets)
+	}
+
+	// 创建错误通道用于收集错误信息
+	errorChan := make(chan error, workers)
+	var wg sync.WaitGroup
+
+	// worker循环，worker池缩容时在领取下一个任务前退出
+	var pool *workerPool
+	worker := func(workerID int) {
+		defer wg.Done()
+		retired := false
+		defer func() {
+			if !retired {
+				pool.exited()
+			}
+			if rec := recover(); rec != nil {
+				select {
+				case errorChan <- fmt.Errorf("worker %d panic: %v", workerID, rec):
+				case <-ctx.Done():
+				}
+			}
+		}()
+
+		for {
+			var resized <-chan struct{}
+			if retired, resized = pool.retire(); retired {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return // 优先检查取消信号
+			case <-resized:
+				continue
+			case task, ok := <-requestQueue:
+				if !ok {
+					return
+				}
+				for i := 0; i < permits; i++ {
+					if err := limiter.wait(ctx); err != nil {
+						r.cancelTask(task, err) // 在限流前再次检查
+						return
+					}
+				}
+				r.emit(Event{Type: EventRowStarted, RowIndex: task.RowIndex})
+				if r.compareTargets != nil {
+					r.finishRow(r.compareRequest(ctx, task, maxRetries))
+				} else {
+					r.finishRow(r.sendRequest(ctx, task, maxRetries))
+				}
+			}
+		}
+	}
+
+	// Start worker goroutines - 优化worker管理，增强停止响应
+	nextWorkerID := 0
+	pool = newWorkerPool(0, func() {
+		wg.Add(1)
+		go worker(nextWorkerID)
+		nextWorkerID++
+	})
+	r.controlMu.Lock()
+	r.limiter, r.pool = limiter, pool
+	r.profileActive = len(r.config.Profile) > 0
+	pool.resize(workers)
+	r.controlMu.Unlock()
+
+	// 按流量曲线调整速率
+	stopProfile := make(chan struct{})
+	if len(r.config.Profile) > 0 {
+		r.logf("Following traffic profile: %s", strings.ReplaceAll(FormatStages(r.config.Profile), "\n", "; "))
+		go r.followProfile(r.config.Profile, time.Now(), stopProfile)
+	}
+
+	// 停止派发：关闭队列后不再接受调整，等待worker处理完已领取的任务
+	drain := func() {
+		close(requestQueue)
+		close(stopProfile)
+		r.controlMu.Lock()
+		r.limiter, r.pool = nil, nil
+		r.controlMu.Unlock()
+		wg.Wait()
+		// worker因停止退出后队列中剩下的任务
+		for task := range requestQueue {
+			r.cancelTask(task, ctx.Err())
+		}
+	}
+
+	// 逐行读取数据，边读边派发，内存占用与数据文件大小无关。先读取标题行和第一个数据行
+	header, err := src.Read()
+	var first []string
+	if err == nil {
+		first, err = src.Read()
+	}
+	if err != nil {
+		if err != io.EOF {
+			r.logf("Data file read failed: %v", err)
+		}
+		r.logf("数据文件没有数据行")
+		drain()
+		return summary, nil
+	}
+
+	// 总行数：重新执行失败行时为指定的行数，否则后台统计数据文件的行数，统计完成前按已读取的字节估算
+	r.countsMu.Lock()
+	r.counts, r.outcomes, r.lastProgress = Counts{}, make(RowOutcomes), time.Now()
+	r.total, r.totalExact = 0, false
+	if r.rows != nil {
+		r.total, r.totalExact = len(r.rows), true
+	}
+	r.mismatches = nil
+	r.stats = newStats()
+	stopSample := make(chan struct{})
+	go r.sample(stopSample)
+	defer close(stopSample)
+	r.countsMu.Unlock()
+
+	countCtx, stopCount := context.WithCancel(ctx)
+	defer stopCount()
+	if counter, ok := src.(rowCounter); ok && r.rows == nil {
+		go func() {
+			if total, err := counter.countRows(countCtx); err == nil {
+				r.setTotal(total, true)
+			}
+		}()
+	}
+
+	// 按标题行解析列名并编译模板，配置有误时直接报错，不发送任何请求
+	if err := r.prepare(header); err != nil {
+		drain()
+		return summary, &ConfigError{Err: err}
+	}
+
+	// 打开结果文件，标题行沿用输入数据的标题
+	r.sink = nil
+	if r.config.ResultFile != "" {
+		sink, err := r.openResultSink(src, header)
+		if err != nil {
+			drain()
+			return summary, err
+		}
+		r.sink = sink
+		defer func() {
+			if err := sink.Close(); err != nil {
+				r.logf("Result file write failed: %v", err)
+			}
+		}()
+		r.logf("Writing results to %s", r.config.ResultFile)
+	}
+
+	if src, ok := src.(encoded); ok {
+		r.logf("Data file encoding: %s", src.dataEncoding())
+	}
+	if total, exact := r.currentTotal(); exact {
+		r.logf("Found %d data rows to process", total)
+	} else {
+		r.logf("Streaming data rows, counting the total in the background")
+	}
+	if r.checkpoint != nil && r.checkpoint.Completed() > 0 {
+		r.logf("Resuming from checkpoint, %d rows already succeeded will be skipped", r.checkpoint.Completed())
+	}
+
+	// 处理CSV数据 - 优化处理逻辑
+	rowIndex := 1 // 标题行为第1行，跳过空行的数据源按文件中的实际行号
+	selected := 0 // 已读取的需要执行的数据行
+
+	// 启动错误监控goroutine
+	go func() {
+		for err := range errorChan {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				r.logf("Worker error: %v", err)
+			}
+		}
+	}()
+
+	// 取消时停止派发并等待worker退出
+	cancelled := func(reason string) (Summary, error) {
+		r.logf("%s", reason)
+		drain()
+		close(errorChan)
+		summary.Total, summary.Estimated = r.finalTotal(selected)
+		summary.Counts, summary.Outcomes = r.snapshot(), r.rowOutcomes()
+		summary.Mismatches = r.mismatchRows()
+		summary.Stopped = true
+		summary.Report = r.finalReport(summary.Counts)
+		return summary, nil
+	}
+
+	// 使用更高效的循环，定期检查停止信号
+	checkInterval := 10 // 每10行检查一次停止信号
+	for row := first; ; row, err = src.Read() {
+		if err != nil {
+			// 格式错误的行之后不再读取，与统计行数时一致
+			if err != io.EOF {
+				r.logf("Data file read failed after row %d: %v", rowIndex, err)
+			}
+			break
+		}
+		rowIndex = nextRowIndex(src, rowIndex)
+
+		// 定期检查停止信号，避免处理过多数据
+		if rowIndex%checkInterval == 0 {
+			select {
+			case <-ctx.Done():
+				return cancelled("Execution cancelled during processing")
+			default:
+			}
+		}
+		if !r.selected(rowIndex) {
+			continue
+		}
+		selected++
+		if r.rows == nil && selected%estimateBatch == 0 {
+			if estimate, ok := estimateRows(src, selected); ok {
+				r.setTotal(estimate, false)
+			}
+		}
+
+		// 断点续跑：跳过上次已成功的行
+		if r.checkpoint != nil && r.checkpoint.Done(rowIndex) {
+			r.record(func(c *Counts) { c.Skipped++ })
+			continue
+		}
+
+		paramsJSON, err := r.genParams(row)
+		if err != nil {
+			r.finishRow(&Result{
+				RowIndex: rowIndex,
+				Row:      row,
+				Outcome:  ResultParamError,
+				Error:    fmt.Sprintf("param generation failed: %v", err),
+			}, fmt.Sprintf("Row %d param generation failed: %v", rowIndex, err))
+			// 错误时也检查停止信号
+			select {
+			case <-ctx.Done():
+				return cancelled("Execution cancelled during error handling")
+			default:
+			}
+			continue
+		}
+
+		// 批量发送任务，减少channel操作开销
+		select {
+		case <-ctx.Done():
+			return cancelled("Execution cancelled before sending task")
+		case requestQueue <- RequestTask{
+			ParamsJSON: paramsJSON,
+			RowIndex:   rowIndex,
+			Row:        row,
+		}:
+		}
+	}
+
+	// 数据读完，已读取的行数即为准确的总数
+	stopCount()
+	r.setTotal(selected, true)
+	drain()
+	close(errorChan)
+
+	summary.Total = selected
+
+	summary.Counts, summary.Outcomes = r.snapshot(), r.rowOutcomes()
+	summary.Mismatches = r.mismatchRows()
+	summary.Report = r.finalReport(summary.Counts)
+	return summary, nil
+}
+
+// 执行停止时的总行数：数据已读完时为准确值，否则为统计或估算的值，不少于已读取的行数
+func (r *Runner) finalTotal(selected int) (int, bool) {
+	total, exact := r.currentTotal()
+	if total < selected {
+		total = selected
+	}
+	return total, !exact
+}
+
+// 打开结果文件：Excel 格式写回数据工作簿的副本，其他格式的标题行沿用输入数据的标题
+func (r *Runner) openResultSink(src Source, header []string) (ResultSink, error) {
+	format := r.config.ResultFormat
+	if format == "" {
+		format = ResultFormatFromPath(r.config.ResultFile)
+	}
+	if format == ResultFormatXLSX {
+		return openWorkbookSink(src, r.config.ResultFile)
+	}
+	return OpenResultSink(r.config.ResultFile, format, header)
+}
+
+// 执行结束时的完整报告，含各目标明细和按行的结果
+func (r *Runner) finalReport(counts Counts) Report {
+	report := r.stats.report(true)
+	report.Rows = &counts
+	return report
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 统计已读取行数的数据源
+type countingSource struct {
+	Source
+	rows atomic.Int64
+}
+
+func (s *countingSource) Read() ([]string, error) {
+	row, err := s.Source.Read()
+	if err == nil {
+		s.rows.Add(1)
+	}
+	return row, err
+}
+
+func csvRows(n int) string {
+	var sb strings.Builder
+	sb.WriteString("id\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	return sb.String()
+}
+
+// 停止执行时，已派发的每一行都有最终结果：请求中、等待限流和仍在队列中的行记为取消
+func TestRunStopRecordsEveryDispatchedRow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		select {
+		case <-time.After(20 * time.Millisecond):
+		case <-req.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	config := testConfig(server)
+	config.QPS = 20
+	config.Workers = 4
+	src := &countingSource{Source: NewCSVSource(strings.NewReader(csvRows(200)))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	summary, err := NewRunner(config, nil).Run(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.Stopped {
+		t.Fatal("run was not stopped")
+	}
+	if summary.Cancelled == 0 {
+		t.Error("no row was recorded as cancelled")
+	}
+	// 读取的行中除标题行外，最多只有停止时正在派发的一行没有结果
+	read := int(src.rows.Load()) - 1
+	if done := summary.Done(); done < read-1 || done > read {
+		t.Errorf("rows with an outcome = %d, rows read = %d", done, read)
+	}
+	if got := summary.Success + len(summary.Outcomes); got != summary.Done() {
+		t.Errorf("success + recorded outcomes = %d, want %d", got, summary.Done())
+	}
+	for rowIndex, outcome := range summary.Outcomes {
+		if outcome == ResultExhausted {
+			t.Errorf("row %d reported as %s after stop", rowIndex, outcome)
+		}
+	}
+}
+
+// 各行按响应分类计数，未成功的行记录在 Outcomes 中
+func TestRunOutcomes(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(outcomeHandler(&requests))
+	defer server.Close()
+
+	config := testConfig(server)
+	config.URL = "http://${ip}/api?id=${col:id}"
+	config.MaxRetries = 2
+	summary, err := NewRunner(config, nil).Run(context.Background(), NewCSVSource(strings.NewReader(outcomeData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Counts{Success: 2, ClientError: 1, Exhausted: 2}
+	if summary.Counts != want || summary.Total != 5 || summary.Estimated || summary.Stopped {
+		t.Errorf("summary = %+v, want counts %+v of 5 rows", summary, want)
+	}
+	wantOutcomes := RowOutcomes{3: ResultClientError, 4: ResultExhausted, 5: ResultExhausted}
+	if len(summary.Outcomes) != len(wantOutcomes) {
+		t.Fatalf("outcomes = %v, want %v", summary.Outcomes, wantOutcomes)
+	}
+	for rowIndex, outcome := range wantOutcomes {
+		if summary.Outcomes[rowIndex] != outcome {
+			t.Errorf("row %d outcome = %q, want %q", rowIndex, summary.Outcomes[rowIndex], outcome)
+		}
+	}
+	if got := requests.Load(); got != 7 {
+		t.Errorf("requests = %d, want 7 (retries only for retryable responses)", got)
+	}
+}
+
+// 按 id 列返回不同响应的测试服务器
+const outcomeData = "id\nok\nmissing\ndown\nbusy\nok\n"
+
+func outcomeHandler(requests *atomic.Int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		switch req.URL.Query().Get("id") {
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "busy":
+			io.WriteString(w, "call failed")
+		}
+	})
+}
+
+// 对比模式每行向所有目标发送请求，QPS 按实际请求数限制
+func TestCompareModeRateLimitsRequests(t *testing.T) {
+	var requests atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, `{"ok":true}`)
+	})
+	gray, prod := httptest.NewServer(handler), httptest.NewServer(handler)
+	defer gray.Close()
+	defer prod.Close()
+
+	config := testConfig(gray)
+	config.IPList = append(config.IPList, strings.TrimPrefix(prod.URL, "http://"))
+	config.CompareMode = true
+	config.QPS = 10
+	start := time.Now()
+	summary, err := NewRunner(config, nil).Run(context.Background(), NewCSVSource(strings.NewReader(csvRows(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Counts.Success != 3 || requests.Load() != 6 {
+		t.Fatalf("summary = %+v, requests = %d, want 3 rows and 6 requests", summary, requests.Load())
+	}
+	// 6 个请求按 10 QPS 至少间隔 5 个 100ms；按行限流只需 200ms
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Errorf("elapsed = %v, want at least 500ms for 6 requests at 10 QPS", elapsed)
+	}
+}
+
+// 配置和模板错误以 ConfigError 返回，命令行据此使用参数错误的退出码
+func TestRunConfigError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	invalid := testConfig(server)
+	invalid.URL = ""
+	badTemplate := testConfig(server)
+	badTemplate.BodyTemp = `{"id":"${col:missing}"}`
+	for name, config := range map[string]*Config{"validate": invalid, "prepare": badTemplate} {
+		_, err := NewRunner(config, nil).Run(context.Background(), NewCSVSource(strings.NewReader(csvRows(1))))
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s: error = %v, want ConfigError", name, err)
+		}
+	}
+}
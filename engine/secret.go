@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 密钥引用的前缀，${secret:名称} 从已解锁的保险库中取值
+const secretPrefix = "${secret:"
+
+// SecretRef 引用保险库中密钥的占位符
+func SecretRef(name string) string {
+	return secretPrefix + name + "}"
+}
+
+func lookupSecret(secrets map[string]string, name string) (string, error) {
+	if secrets == nil {
+		return "", fmt.Errorf("引用了密钥 %s，需要先解锁保险库", name)
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("引用的密钥 %s 在保险库中不存在", name)
+	}
+	return value, nil
+}
+
+// 展开文本中的 ${secret:名称}，其他占位符原样保留
+func expandSecrets(text string, secrets map[string]string) (string, error) {
+	return expandRefs(text, secretPrefix, func(name string) (string, error) {
+		return lookupSecret(secrets, name)
+	})
+}
+
+// UsesSecrets 配置中是否引用了保险库中的密钥，引用时需要先解锁保险库才能执行
+func (c *Config) UsesSecrets() bool {
+	texts := []string{c.URL, c.Cookie, c.BodyTemp}
+	texts = append(texts, c.IPList...)
+	for _, header := range c.Headers {
+		texts = append(texts, header.Value)
+	}
+	for _, env := range c.Environments {
+		if env.Name != c.Environment {
+			continue
+		}
+		for _, value := range env.Vars {
+			texts = append(texts, value)
+		}
+	}
+	for _, text := range texts {
+		if strings.Contains(text, secretPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Credential 配置中以明文保存的凭据
+type Credential struct {
+	Field string // 凭据所在位置，如 "Cookie"、"请求体 token"
+	Name  string // 移入保险库时使用的密钥名称
+	Value string
+}
+
+// 名称中含有这些词的请求头、参数和变量视为凭据（比较时忽略大小写、- 和 _）
+var credentialWords = []string{"cookie", "token", "authorization", "password", "passwd", "secret", "apikey", "accesskey", "session"}
+
+func isCredentialName(name string) bool {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for _, word := range credentialWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否为需要移入保险库的明文值，已包含占位符的值视为已处理
+func isPlainCredential(value string) bool {
+	return strings.TrimSpace(value) != "" && !strings.Contains(value, "${")
+}
+
+var (
+	// JSON 中的字符串字段 "key": "value"
+	jsonFieldPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)"((?:[^"\\]|\\.)*)"`)
+	// 查询串或表单中的 key=value
+	queryFieldPattern = regexp.MustCompile(`(^|[?&])([^=&?#]+)=([^&#]*)`)
+)
+
+// FindCredentials 找出配置中以明文保存的凭据：Cookie，以及名称像凭据（含 credentialWords
+// 中的词）的请求头、请求地址和请求体中的参数、环境变量。只按名称识别，不检查值的内容，
+// 名称普通的字段（如 "key"、"sign"）或 text 格式请求体中的凭据不会被发现；
+// 已包含占位符的值视为已处理。existing 为保险库中已有的密钥，
+// 值相同时沿用已有名称，名称冲突时加数字后缀
+func FindCredentials(c *Config, existing map[string]string) []Credential {
+	copied := *c
+	var creds []Credential
+	scanCredentials(&copied, func(field, name, value string) string {
+		creds = append(creds, Credential{Field: field, Name: name, Value: value})
+		return ""
+	})
+	return assignSecretNames(creds, existing)
+}
+
+// ReplaceCredentials 把 FindCredentials 找到的凭据替换为 ${secret:名称}，
+// 环境列表会复制一份，不修改原配置的变量表
+func ReplaceCredentials(c *Config, creds []Credential) {
+	names := make(map[string]string, len(creds))
+	for _, cred := range creds {
+		names[cred.Field+"\x00"+cred.Value] = cred.Name
+	}
+	environments := make([]Environment, len(c.Environments))
+	for i, env := range c.Environments {
+		vars := make(map[string]string, len(env.Vars))
+		for name, value := range env.Vars {
+			vars[name] = value
+		}
+		environments[i] = Environment{Name: env.Name, Vars: vars}
+	}
+	c.Environments = environments
+	c.Headers = append([]Header(nil), c.Headers...)
+
+	scanCredentials(c, func(field, _, value string) string {
+		if name, ok := names[field+"\x00"+value]; ok {
+			return SecretRef(name)
+		}
+		return ""
+	})
+}
+
+// 遍历配置中的明文凭据，visit 返回非空时用返回值替换该凭据
+func scanCredentials(c *Config, visit func(field, name, value string) string) {
+	if isPlainCredential(c.Cookie) {
+		if ref := visit("Cookie", "cookie", strings.TrimSpace(c.Cookie)); ref != "" {
+			c.Cookie = ref
+		}
+	}
+	for i, header := range c.Headers {
+		if isCredentialName(header.Name) && isPlainCredential(header.Value) {
+			if ref := visit("请求头 "+header.Name, secretName(header.Name), header.Value); ref != "" {
+				c.Headers[i].Value = ref
+			}
+		}
+	}
+	c.URL = scanQuery(c.URL, "请求地址 ", visit)
+
+	switch c.RequestBodyFormat() {
+	case BodyFormatJSON:
+		c.BodyTemp = jsonFieldPattern.ReplaceAllStringFunc(c.BodyTemp, func(match string) string {
+			groups := jsonFieldPattern.FindStringSubmatch(match)
+			var key, value string
+			if json.Unmarshal([]byte(`"`+groups[1]+`"`), &key) != nil || json.Unmarshal([]byte(`"`+groups[3]+`"`), &value) != nil {
+				return match
+			}
+			if !isCredentialName(key) || !isPlainCredential(value) {
+				return match
+			}
+			if ref := visit("请求体 "+key, secretName(key), value); ref != "" {
+				return `"` + groups[1] + `"` + groups[2] + `"` + ref + `"`
+			}
+			return match
+		})
+	case BodyFormatForm:
+		c.BodyTemp = scanQuery(c.BodyTemp, "请求体 ", visit)
+	}
+
+	for _, env := range c.Environments {
+		names := make([]string, 0, len(env.Vars))
+		for name := range env.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value := env.Vars[name]; isCredentialName(name) && isPlainCredential(value) {
+				field := fmt.Sprintf("环境 %s 变量 %s", env.Name, name)
+				if ref := visit(field, secretName(env.Name+"_"+name), value); ref != "" {
+					env.Vars[name] = ref
+				}
+			}
+		}
+	}
+}
+
+// 查找查询串或表单中的凭据参数
+func scanQuery(text, field string, visit func(field, name, value string) string) string {
+	return queryFieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := queryFieldPattern.FindStringSubmatch(match)
+		key, err1 := url.QueryUnescape(groups[2])
+		value, err2 := url.QueryUnescape(groups[3])
+		if err1 != nil || err2 != nil || !isCredentialName(key) || !isPlainCredential(value) {
+			return match
+		}
+		if ref := visit(field+key, secretName(key), value); ref != "" {
+			return groups[1] + groups[2] + "=" + ref
+		}
+		return match
+	})
+}
+
+// 把请求头或参数名转为密钥名称，只保留字母、数字和下划线
+func secretName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	if sb.Len() == 0 {
+		return "secret"
+	}
+	return sb.String()
+}
+
+// 为凭据分配不冲突的密钥名称，值相同的凭据共用一个名称
+func assignSecretNames(creds []Credential, existing map[string]string) []Credential {
+	taken := make(map[string]string, len(existing))
+	for name, value := range existing {
+		taken[name] = value
+	}
+	for i, cred := range creds {
+		name := cred.Name
+		for n := 2; ; n++ {
+			value, ok := taken[name]
+			if !ok || value == cred.Value {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", cred.Name, n)
+		}
+		taken[name] = cred.Value
+		creds[i].Name = name
+	}
+	return creds
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 迁移到保险库后发送的请求必须与迁移前完全一致，值中转义过的字符不能被还原
+func TestMigratedCredentialsKeepEscaping(t *testing.T) {
+	var mu sync.Mutex
+	var gotURI, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		gotURI, gotBody = req.RequestURI, string(body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:        "http://${ip}/api?token=a%2Bb%26c&id=${col:id}",
+		BodyFormat: BodyFormatForm,
+		BodyTemp:   "user=${col:name}&password=p%26q",
+		IPList:     []string{strings.TrimPrefix(server.URL, "http://")},
+		QPS:        100,
+		Workers:    1,
+		MaxRetries: 1,
+		ParamMode:  ParamModeObject,
+		ParamMappings: []ParamMapping{
+			{CSVColumn: "id", ParamName: "id", ParamType: "string"},
+		},
+	}
+	creds := FindCredentials(config, nil)
+	if len(creds) != 2 {
+		t.Fatalf("FindCredentials found %d credentials, want 2: %+v", len(creds), creds)
+	}
+	secrets := make(map[string]string)
+	for _, cred := range creds {
+		secrets[cred.Name] = cred.Value
+	}
+	if secrets["token"] != "a+b&c" || secrets["password"] != "p&q" {
+		t.Fatalf("migrated secrets = %v, want decoded values", secrets)
+	}
+	ReplaceCredentials(config, creds)
+	if strings.Contains(config.URL, "a%2Bb") || strings.Contains(config.BodyTemp, "p%26q") {
+		t.Fatalf("credentials not replaced: url %q, body %q", config.URL, config.BodyTemp)
+	}
+	config.Secrets = secrets
+
+	summary, err := NewRunner(config, nil).Run(context.Background(), NewCSVSource(strings.NewReader("id,name\n1 2,a&b\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Success != 1 {
+		t.Fatalf("succeeded = %d, want 1", summary.Success)
+	}
+	if want := "/api?token=a%2Bb%26c&id=1+2"; gotURI != want {
+		t.Errorf("request URI = %q, want %q", gotURI, want)
+	}
+	if want := "user=a%26b&password=p%26q"; gotBody != want {
+		t.Errorf("request body = %q, want %q", gotBody, want)
+	}
+}
+
+// 环境变量和保险库中的值按所在位置转义，地址开头的变量视为协议和主机
+func TestConstantPlaceholdersEscaped(t *testing.T) {
+	compiler := newTemplateCompiler([]string{"id"},
+		map[string]string{"base": "http://host:8080/v1", "dir": "a/b", "q": "x&y", "prefix": "api/v1"},
+		map[string]string{"key": "k=1"})
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"${var:base}/items/${var:dir}?q=${var:q}", "http://host:8080/v1/items/a/b?q=x%26y"},
+		{"http://host/${var:prefix}/orders/${col:id}", "http://host/api/v1/orders/7"},
+		{"http://host/${var:prefix}/${secret:key}?dir=${var:prefix}", "http://host/api/v1/k=1?dir=api%2Fv1"},
+		{"http://h/p?key=${secret:key}&id=${col:id}", "http://h/p?key=k%3D1&id=7"},
+		{"http://h/p?raw=a%2Bb", "http://h/p?raw=a%2Bb"},
+	}
+	for _, tt := range tests {
+		tmpl, err := compiler.compileURL(tt.url)
+		if err != nil {
+			t.Fatalf("compileURL(%q): %v", tt.url, err)
+		}
+		got, err := tmpl.render(&templateVars{row: []string{"7"}})
+		if err != nil {
+			t.Fatalf("render(%q): %v", tt.url, err)
+		}
+		if got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 输入数据格式
+const (
+	InputFormatCSV   = "csv"
+	InputFormatJSONL = "jsonl" // 每行一个JSON对象或数组，参数映射按JSON路径取值
+	InputFormatXLSX  = "xlsx"  // Excel 工作簿，读取一个工作表，标题行之前的行和空行忽略
+)
+
+// InputFormats 支持的输入数据格式
+var InputFormats = []string{InputFormatCSV, InputFormatJSONL, InputFormatXLSX}
+
+// JSONL 数据的虚拟标题行：每行作为单独的一列，结果文件中的列名为 line
+var jsonlHeader = []string{"line"}
+
+// InputFormatFromPath 根据文件扩展名推断输入数据格式，无法识别时使用CSV
+func InputFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return InputFormatJSONL
+	case ".xlsx":
+		return InputFormatXLSX
+	default:
+		return InputFormatCSV
+	}
+}
+
+// SourceFormat 返回实际使用的输入数据格式，未配置时为CSV
+func (c *Config) SourceFormat() string {
+	format := strings.ToLower(strings.TrimSpace(c.InputFormat))
+	if format == "" {
+		return InputFormatCSV
+	}
+	return format
+}
+
+// jsonlSource JSON Lines 数据源，第一次读取返回虚拟标题行，之后每次返回一行原文，空行忽略
+type jsonlSource struct {
+	reader *bufio.Reader
+	header bool
+	lines  int // 已读取的文件行数，含空行
+}
+
+// NewJSONLSource 从JSON Lines内容创建数据源，行号与CSV一致：第1行为虚拟标题行，文件的第N行为第N+1行，
+// 空行虽然被忽略，仍计入行号
+func NewJSONLSource(r io.Reader) Source {
+	return &jsonlSource{reader: bufio.NewReader(r)}
+}
+
+func (s *jsonlSource) Read() ([]string, error) {
+	if !s.header {
+		s.header = true
+		// 去掉文件开头的 UTF-8 BOM
+		if bom, err := s.reader.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+			s.reader.Discard(3)
+		}
+		return jsonlHeader, nil
+	}
+	for {
+		line, err := s.reader.ReadString('\n')
+		if line != "" {
+			s.lines++
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return []string{line}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *jsonlSource) lastRowIndex() (int, bool) {
+	return s.lines + 1, s.header
+}
+
+// NewSource 按输入格式创建数据源，Excel 工作簿需要用 OpenSource 打开
+func NewSource(r io.Reader, format string) Source {
+	if format == InputFormatJSONL {
+		return NewJSONLSource(r)
+	}
+	return NewCSVSource(r)
+}
+
+// DataSource 打开的数据文件，用完后需要关闭
+type DataSource interface {
+	Source
+	io.Closer
+}
+
+// OpenSource 按配置的输入格式打开数据文件
+func OpenSource(path string, config *Config) (DataSource, error) {
+	format := config.SourceFormat()
+	if !contains(InputFormats, format) {
+		return nil, fmt.Errorf("不支持的数据文件格式: %s", config.InputFormat)
+	}
+	if format == InputFormatXLSX {
+		return openXLSXSource(path, config.Sheet, config.HeaderRow)
+	}
+	if !contains(Encodings, config.DataEncoding()) {
+		return nil, fmt.Errorf("不支持的文件编码: %s", config.Encoding)
+	}
+	src, err := openFileSource(path, format, config.DataEncoding())
+	if err != nil {
+		return nil, fmt.Errorf("打开数据文件失败: %v", err)
+	}
+	return src, nil
+}
+
+// ReadHeader 读取数据文件的标题行，JSONL 数据为虚拟标题行
+func ReadHeader(path string, config *Config) ([]string, error) {
+	src, err := OpenSource(path, config)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	header, err := src.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("数据文件为空")
+	}
+	return header, err
+}
+
+// 解析 JSONL 数据的一行，只接受JSON对象或数组
+func decodeJSONLine(row []string) (interface{}, error) {
+	if len(row) == 0 {
+		return nil, fmt.Errorf("空行")
+	}
+	decoder := json.NewDecoder(strings.NewReader(row[0]))
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("JSON格式错误: %v", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("JSON格式错误: 一行只能包含一个JSON值")
+	}
+	switch root.(type) {
+	case map[string]interface{}, []interface{}:
+		return root, nil
+	}
+	return nil, fmt.Errorf("每行必须是JSON对象或数组")
+}
+
+// 解析参数映射中的JSON路径，纯数字表示数组下标
+func parseMappingPath(column string) ([]interface{}, error) {
+	if index, err := strconv.Atoi(column); err == nil {
+		return []interface{}{index}, nil
+	}
+	if column == "" || column == "$" {
+		return nil, fmt.Errorf("JSON路径不能为空")
+	}
+	return parseJSONPath(column)
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// JSONL 的行号为文件行号加1，空行不返回但计入行号
+func TestJSONLRowIndexCountsBlankLines(t *testing.T) {
+	data := "{\"id\":1}\n\n{\"id\":2}\r\n  \n\n{\"id\":3}"
+	src := NewJSONLSource(strings.NewReader(data))
+	var got []int
+	rowIndex := 0
+	for {
+		row, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rowIndex = nextRowIndex(src, rowIndex)
+		if rowIndex > 1 {
+			got = append(got, rowIndex)
+			if !strings.HasPrefix(row[0], "{") {
+				t.Errorf("row %d = %q", rowIndex, row[0])
+			}
+		}
+	}
+	if want := []int{2, 4, 7}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("row indexes = %v, want %v", got, want)
+	}
+}
+
+// 导出失败行时按同样的行号选择，导出的是失败的那几行
+func TestExportLinesUsesFileLineNumbers(t *testing.T) {
+	data := "{\"id\":1}\n\n{\"id\":2}\n\n{\"id\":3}\n"
+	var out bytes.Buffer
+	written, err := exportLines(NewJSONLSource(strings.NewReader(data)), &out, map[int]bool{4: true, 6: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"id\":2}\n{\"id\":3}\n"; written != 2 || out.String() != want {
+		t.Errorf("exported %d rows %q, want %q", written, out.String(), want)
+	}
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 直方图精度：每个2的幂区间细分为 histogramHalf 个桶，相对误差不超过 1/histogramHalf
+const (
+	histogramBits  = 7
+	histogramSub   = 1 << histogramBits // 小于该值（微秒）的延迟精确记录
+	histogramHalf  = histogramSub / 2
+	histogramShift = 40 // 最大可记录约 2^40 微秒
+)
+
+// Histogram HDR风格的延迟直方图，以微秒记录，按对数分段、段内线性细分，内存占用固定
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    int64
+	max    int64
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{counts: make([]int64, histogramSub+histogramShift*histogramHalf)}
+}
+
+func histogramIndex(v int64) int {
+	if v < histogramSub {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - histogramBits
+	top := v >> shift // [histogramHalf, histogramSub)
+	return histogramSub + (shift-1)*histogramHalf + int(top-histogramHalf)
+}
+
+// 桶对应区间的上界，用于计算分位数
+func histogramValue(index int) int64 {
+	if index < histogramSub {
+		return int64(index)
+	}
+	index -= histogramSub
+	shift := index/histogramHalf + 1
+	top := int64(index%histogramHalf + histogramHalf)
+	return (top+1)<<shift - 1
+}
+
+// Record 记录一次延迟
+func (h *Histogram) Record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	index := histogramIndex(v)
+	if index >= len(h.counts) {
+		index = len(h.counts) - 1
+	}
+	h.counts[index]++
+	h.total++
+	h.sum += v
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Quantile 返回分位数（0~1）对应的延迟，没有记录时为0
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			v := histogramValue(i)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// LatencyStats 延迟统计，单位毫秒
+type LatencyStats struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"meanMs"`
+	P50   float64 `json:"p50Ms"`
+	P90   float64 `json:"p90Ms"`
+	P99   float64 `json:"p99Ms"`
+	Max   float64 `json:"maxMs"`
+}
+
+func (h *Histogram) stats() LatencyStats {
+	s := LatencyStats{Count: h.total}
+	if h.total == 0 {
+		return s
+	}
+	s.Mean = float64(h.sum) / float64(h.total) / 1000
+	s.P50 = durationMs(h.Quantile(0.50))
+	s.P90 = durationMs(h.Quantile(0.90))
+	s.P99 = durationMs(h.Quantile(0.99))
+	s.Max = float64(h.max) / 1000
+	return s
+}
+
+func (s LatencyStats) String() string {
+	return fmt.Sprintf("p50 %.1fms, p90 %.1fms, p99 %.1fms, max %.1fms", s.P50, s.P90, s.P99, s.Max)
+}
+
+// targetStats 单个目标的请求统计
+type targetStats struct {
+	latency  *Histogram
+	requests int64
+	errors   int64
+}
+
+// Stats 执行期间按请求（含重试）采集的统计，worker并发写入
+type Stats struct {
+	mu      sync.Mutex
+	start   time.Time
+	overall targetStats
+	targets map[string]*targetStats
+	window  targetStats // 上次采样以来的请求，用于实时曲线
+}
+
+func newStats() *Stats {
+	return &Stats{
+		start:   time.Now(),
+		overall: targetStats{latency: newHistogram()},
+		targets: make(map[string]*targetStats),
+		window:  targetStats{latency: newHistogram()},
+	}
+}
+
+// 记录一次请求。received 为 false 表示没有收到响应，此时只计入错误不计入延迟
+func (s *Stats) record(target string, latency time.Duration, received, failed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.targets[target]
+	if !ok {
+		t = &targetStats{latency: newHistogram()}
+		s.targets[target] = t
+	}
+	for _, stats := range []*targetStats{&s.overall, t, &s.window} {
+		stats.requests++
+		if failed {
+			stats.errors++
+		}
+		if received {
+			stats.latency.Record(latency)
+		}
+	}
+}
+
+// 取出上次采样以来的请求数、失败数和延迟分布，并开始新的采样窗口
+func (s *Stats) sampleWindow() (requests, errors int64, latency LatencyStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests, errors, latency = s.window.requests, s.window.errors, s.window.latency.stats()
+	s.window = targetStats{latency: newHistogram()}
+	return requests, errors, latency
+}
+
+// TargetReport 单个目标的统计
+type TargetReport struct {
+	Target    string       `json:"target"`
+	Requests  int64        `json:"requests"`
+	Errors    int64        `json:"errors"`
+	ErrorRate float64      `json:"errorRate"`
+	Latency   LatencyStats `json:"latency"`
+}
+
+// Report 执行统计报告
+type Report struct {
+	Start      time.Time      `json:"start"`
+	ElapsedSec float64        `json:"elapsedSec"`
+	Requests   int64          `json:"requests"`   // 发送的请求数（含重试）
+	Throughput float64        `json:"throughput"` // 每秒完成的请求数
+	Errors     int64          `json:"errors"`     // 未收到响应或判定为失败、重试的请求数
+	ErrorRate  float64        `json:"errorRate"`
+	Latency    LatencyStats   `json:"latency"`
+	Targets    []TargetReport `json:"targets,omitempty"`
+	Rows       *Counts        `json:"rows,omitempty"` // 按行的最终结果，执行结束时填写
+}
+
+func errorRate(errors, requests int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// report 生成当前的统计报告，withTargets 为 false 时省略各目标的明细
+func (s *Stats) report(withTargets bool) Report {
+	if s == nil {
+		return Report{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start)
+	report := Report{
+		Start:      s.start,
+		ElapsedSec: elapsed.Seconds(),
+		Requests:   s.overall.requests,
+		Errors:     s.overall.errors,
+		ErrorRate:  errorRate(s.overall.errors, s.overall.requests),
+		Latency:    s.overall.latency.stats(),
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(s.overall.requests) / elapsed.Seconds()
+	}
+	if withTargets {
+		for target, t := range s.targets {
+			report.Targets = append(report.Targets, TargetReport{
+				Target:    target,
+				Requests:  t.requests,
+				Errors:    t.errors,
+				ErrorRate: errorRate(t.errors, t.requests),
+				Latency:   t.latency.stats(),
+			})
+		}
+		sort.Slice(report.Targets, func(i, k int) bool {
+			return report.Targets[i].Target < report.Targets[k].Target
+		})
+	}
+	return report
+}
+
+// Text 文本格式的报告，用于日志和导出
+func (r Report) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Run started %s, elapsed %.1fs\n", r.Start.Format("2006-01-02 15:04:05"), r.ElapsedSec)
+	if r.Rows != nil {
+		fmt.Fprintf(&sb, "Rows: %s\n", r.Rows)
+	}
+	fmt.Fprintf(&sb, "Requests: %d, throughput %.1f/s, errors %d (%.2f%%)\n",
+		r.Requests, r.Throughput, r.Errors, r.ErrorRate*100)
+	fmt.Fprintf(&sb, "Latency: mean %.1fms, %s\n", r.Latency.Mean, r.Latency)
+	if len(r.Targets) > 0 {
+		fmt.Fprintf(&sb, "%-24s %10s %10s %9s %9s %9s %9s %9s\n",
+			"target", "requests", "errors", "mean", "p50", "p90", "p99", "max")
+		for _, t := range r.Targets {
+			fmt.Fprintf(&sb, "%-24s %10d %10d %9.1f %9.1f %9.1f %9.1f %9.1f\n",
+				t.Target, t.Requests, t.Errors, t.Latency.Mean, t.Latency.P50, t.Latency.P90, t.Latency.P99, t.Latency.Max)
+		}
+	}
+	return sb.String()
+}
+
+// WriteReport 导出报告，.json 扩展名为JSON格式，其余为文本格式
+func WriteReport(path string, report Report) error {
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var err error
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			return err
+		}
+	} else {
+		data = []byte(report.Text())
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("写入报告失败: %v", err)
+	}
+	return nil
+}
+
+// 采样周期
+const sampleInterval = time.Second
+
+// 每秒发出一次实时采样事件，stop 关闭时返回
+func (r *Runner) sample(stop <-chan struct{}) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	last, lastCounts := time.Now(), r.snapshot()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			requests, errors, latency := r.stats.sampleWindow()
+			counts := r.snapshot()
+			seconds := now.Sub(last).Seconds()
+			r.emit(Event{Type: EventSample, Sample: Sample{
+				Time:      now,
+				QPS:       float64(requests) / seconds,
+				TargetQPS: r.targetQPS(),
+				Errors:    float64(errors) / seconds,
+				Latency:   latency,
+				Rows:      counts.sub(lastCounts),
+			}})
+			last, lastCounts = now, counts
+		}
+	}
+}
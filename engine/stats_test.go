@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := newHistogram()
+	if h.Quantile(0.5) != 0 {
+		t.Error("empty histogram quantile is not 0")
+	}
+	for ms := 1; ms <= 1000; ms++ {
+		h.Record(time.Duration(ms) * time.Millisecond)
+	}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.9, 900 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+		{1, 1000 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		// 分段直方图的相对误差在 1% 以内
+		if diff := got - tt.want; diff < -tt.want/100 || diff > tt.want/100 {
+			t.Errorf("Quantile(%v) = %v, want about %v", tt.q, got, tt.want)
+		}
+	}
+	s := h.stats()
+	if s.Count != 1000 || s.Max != 1000 || s.Mean < 500 || s.Mean > 501 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	// 每个值都落在上界不小于它的桶中，桶的上界单调递增
+	prev := int64(-1)
+	for v := int64(0); v < 1<<22; v = v*5/4 + 1 {
+		index := histogramIndex(v)
+		if upper := histogramValue(index); upper < v {
+			t.Fatalf("value %d in bucket %d with upper bound %d", v, index, upper)
+		}
+		if upper := histogramValue(index); upper < prev {
+			t.Fatalf("bucket bounds not increasing at %d", v)
+		}
+		prev = histogramValue(index)
+	}
+}
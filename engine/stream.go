@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// 数据源逐行读取、边读边派发，不把整个文件读入内存。进度条需要的总行数由数据源另外提供：
+// 能重新打开的数据文件在后台再读一遍统计准确的行数，统计完成前按已读取的字节估算
+
+// rowCounter 能独立统计数据行数的数据源
+type rowCounter interface {
+	// 统计数据行数（不含标题行），读到格式错误的行时停止，与执行时一致
+	countRows(ctx context.Context) (int, error)
+}
+
+// positioned 能报告读取位置的数据源
+type positioned interface {
+	position() (read, size int64)
+}
+
+// encoded 能报告实际使用的字符编码的数据源
+type encoded interface {
+	dataEncoding() string
+}
+
+// rowNumbered 会跳过空行的数据源，报告最近读取的一行在文件中的实际行号，
+// 使日志、重新执行失败行和结果写回使用的行号与文件一致
+type rowNumbered interface {
+	// 最近一次 Read 返回的行的行号（从1开始），无法确定时返回 false
+	lastRowIndex() (int, bool)
+}
+
+// 刚读取的一行的行号：数据源能报告实际行号时以其为准，否则为上一行的行号加1
+func nextRowIndex(src Source, prev int) int {
+	if src, ok := src.(rowNumbered); ok {
+		if rowIndex, ok := src.lastRowIndex(); ok {
+			return rowIndex
+		}
+	}
+	return prev + 1
+}
+
+// 每读取这么多行更新一次估算的总行数，行数太少时预读的缓冲会使估算偏小
+const estimateBatch = 100
+
+// 统计已读取字节数的 Reader
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// fileSource 从文件读取的 CSV / JSONL 数据源，按文件编码转为 UTF-8 后解析
+type fileSource struct {
+	Source
+	io.Closer
+	path     string
+	format   string
+	encoding string // 实际使用的编码，自动检测时为检测结果
+	size     int64
+	reader   *countingReader
+	buffered *bufio.Reader
+}
+
+func openFileSource(path, format, encoding string) (*fileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader := &countingReader{r: file}
+	buffered := bufio.NewReaderSize(reader, detectSize)
+	decoded, encoding, err := decodeReader(buffered, encoding)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileSource{
+		Source:   NewSource(decoded, format),
+		Closer:   file,
+		path:     path,
+		format:   format,
+		encoding: encoding,
+		size:     info.Size(),
+		reader:   reader,
+		buffered: buffered,
+	}, nil
+}
+
+// 已解析的字节数，不含检测编码用的缓冲，但含解码和解析器预读的缓冲（约8KB），
+// 读取的行数较少时估算不准，文件较小时可能一次就读到末尾。只在读取数据的goroutine中调用
+func (s *fileSource) position() (read, size int64) {
+	return s.reader.n.Load() - int64(s.buffered.Buffered()), s.size
+}
+
+func (s *fileSource) lastRowIndex() (int, bool) {
+	if src, ok := s.Source.(rowNumbered); ok {
+		return src.lastRowIndex()
+	}
+	return 0, false
+}
+
+func (s *fileSource) dataEncoding() string {
+	return s.encoding
+}
+
+func (s *fileSource) countRows(ctx context.Context) (int, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	decoded, _, err := decodeReader(bufio.NewReaderSize(file, detectSize), s.encoding)
+	if err != nil {
+		return 0, err
+	}
+	src := NewSource(decoded, s.format)
+	rows := 0
+	for {
+		if rows%estimateBatch == 0 && ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if _, err := src.Read(); err != nil {
+			break
+		}
+		rows++
+	}
+	if rows == 0 {
+		return 0, nil
+	}
+	return rows - 1, nil
+}
+
+// Excel 工作表在打开时已全部读入，行数直接可得
+func (s *xlsxSource) countRows(context.Context) (int, error) {
+	if len(s.rows) == 0 {
+		return 0, nil
+	}
+	return len(s.rows) - 1, nil
+}
+
+// 按已读取的字节数估算数据行数，rows 为已读取的数据行数；无法估算时返回 false
+func estimateRows(src Source, rows int) (int, bool) {
+	pos, ok := src.(positioned)
+	if !ok {
+		return 0, false
+	}
+	read, size := pos.position()
+	if read <= 0 || read >= size {
+		return 0, false
+	}
+	return int(float64(rows) * float64(size) / float64(read)), true
+}
+
+// 更新数据行总数。exact 为 false 时是估算值，得到准确的总数后不再接受估算值
+func (r *Runner) setTotal(total int, exact bool) {
+	r.countsMu.Lock()
+	defer r.countsMu.Unlock()
+	if r.totalExact && !exact {
+		return
+	}
+	r.total, r.totalExact = total, exact
+}
+
+// 当前的数据行总数及是否为准确值
+func (r *Runner) currentTotal() (int, bool) {
+	r.countsMu.Lock()
+	defer r.countsMu.Unlock()
+	return r.total, r.totalExact
+}
@@ -0,0 +1,533 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 模板占位符语法：${名称} 或 ${类型:参数}，$${ 输出字面量 ${
+//
+//	${col:orderId}   当前行的列值，按列名（不区分大小写）或列索引
+//	${param:name}    生成的参数值，对象模式按参数名，数组模式按位置
+//	${jsonParam}     生成的完整参数JSON
+//	${ip}            本次请求选中的 ip:port
+//	${row}           行号（从1开始，含标题行）
+//	${uuid}          随机UUID
+//	${now:layout}    当前时间，layout 使用Go时间格式，省略时为 2006-01-02 15:04:05
+//	${env:TOKEN}     环境变量，开始执行时读取
+//	${var:name}      当前所选环境（Config.Environments）中的变量，开始执行时读取
+//	${secret:name}   本地加密保险库中的密钥，开始执行时读取，需要先解锁保险库
+const defaultNowLayout = "2006-01-02 15:04:05"
+
+// templateVars 渲染一行请求时可用的变量
+type templateVars struct {
+	row      []string
+	rowIndex int
+	ip       string
+	params   []byte      // 生成的参数JSON
+	decoded  interface{} // 按需解析的参数，供 ${param:...} 使用
+	parsed   bool
+	mask     bool // 预览时用 secretMask 代替来自保险库的值
+}
+
+// 预览中代替密钥值显示的内容
+const secretMask = "******"
+
+// 解析参数JSON，同一行只解析一次
+func (v *templateVars) param(name string) (string, error) {
+	if !v.parsed {
+		v.parsed = true
+		decoder := json.NewDecoder(bytes.NewReader(v.params))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v.decoded); err != nil {
+			return "", fmt.Errorf("参数解析失败: %v", err)
+		}
+	}
+
+	var value interface{}
+	switch params := v.decoded.(type) {
+	case map[string]interface{}:
+		value = params[name]
+	case []interface{}:
+		index, err := strconv.Atoi(name)
+		if err != nil || index < 0 || index >= len(params) {
+			return "", fmt.Errorf("数组参数中不存在位置 %s", name)
+		}
+		value = params[index]
+	}
+	switch val := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	default:
+		data, err := json.Marshal(val)
+		return string(data), err
+	}
+}
+
+// placeholder 编译后的单个占位符
+type placeholder struct {
+	kind   string // col, param, jsonParam, ip, row, uuid, now, value, literal
+	arg    string // value 类型为开始执行时求出的值，与列值一样按所在位置转义
+	column int    // col 类型解析出的列索引
+	secret bool   // 值来自保险库，预览时隐藏
+}
+
+func (p placeholder) render(vars *templateVars) (string, error) {
+	if p.secret && vars.mask {
+		return secretMask, nil
+	}
+	switch p.kind {
+	case "literal", "value":
+		return p.arg, nil
+	case "col":
+		if p.column < len(vars.row) {
+			return vars.row[p.column], nil
+		}
+		return "", nil
+	case "param":
+		return vars.param(p.arg)
+	case "jsonParam":
+		return string(vars.params), nil
+	case "ip":
+		return vars.ip, nil
+	case "row":
+		return strconv.Itoa(vars.rowIndex), nil
+	case "uuid":
+		return newUUID(), nil
+	case "now":
+		return time.Now().Format(p.arg), nil
+	}
+	return "", fmt.Errorf("未知占位符类型: %s", p.kind)
+}
+
+// textTemplate 编译后的字符串模板，由字面量和占位符交替组成
+type textTemplate struct {
+	parts     []placeholder
+	escape    func(string) string // 占位符值的转义函数，字面量不转义
+	rawValues bool                // 环境变量、运行变量和密钥的值视为模板的一部分，不转义
+}
+
+// 是否不含任何占位符
+func (t *textTemplate) isLiteral() bool {
+	for _, part := range t.parts {
+		if part.kind != "literal" {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *textTemplate) render(vars *templateVars) (string, error) {
+	if len(t.parts) == 1 && t.escape == nil {
+		return t.parts[0].render(vars)
+	}
+	var sb strings.Builder
+	for _, part := range t.parts {
+		value, err := part.render(vars)
+		if err != nil {
+			return "", err
+		}
+		if t.escape != nil && part.kind != "literal" && !(part.kind == "value" && t.rawValues) && !(part.secret && vars.mask) {
+			value = t.escape(value)
+		}
+		sb.WriteString(value)
+	}
+	return sb.String(), nil
+}
+
+// templateCompiler 编译模板时使用的列名索引、当前环境的变量和保险库中的密钥
+type templateCompiler struct {
+	columns    map[string]int
+	vars       map[string]string
+	secretVars map[string]bool   // 值引用了密钥的变量
+	secrets    map[string]string // 保险库未解锁时为空
+}
+
+func newTemplateCompiler(header []string, vars, secrets map[string]string) *templateCompiler {
+	return &templateCompiler{columns: columnIndex(header), vars: vars, secrets: secrets}
+}
+
+// 编译字符串模板
+func (c *templateCompiler) compileText(text string) (*textTemplate, error) {
+	t := &textTemplate{}
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, placeholder{kind: "literal", arg: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for len(text) > 0 {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			literal.WriteString(text)
+			break
+		}
+		// $${ 转义为字面量 ${
+		if start > 0 && text[start-1] == '$' {
+			literal.WriteString(text[:start-1])
+			literal.WriteString("${")
+			text = text[start+2:]
+			continue
+		}
+		end := strings.Index(text[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("占位符未闭合: %s", text[start:])
+		}
+		literal.WriteString(text[:start])
+
+		p, err := c.compilePlaceholder(text[start+2 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		if p.kind == "literal" {
+			literal.WriteString(p.arg)
+		} else {
+			flush()
+			t.parts = append(t.parts, p)
+		}
+		text = text[start+end+1:]
+	}
+	flush()
+	if len(t.parts) == 0 {
+		t.parts = []placeholder{{kind: "literal"}}
+	}
+	return t, nil
+}
+
+// 编译单个占位符，运行期不变的值（如环境变量）在此直接求值。求出的值不是模板原文，
+// 仍按所在位置转义，否则值中的 & + / 等字符会改变请求的含义
+func (c *templateCompiler) compilePlaceholder(expr string) (placeholder, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(expr), ":")
+	switch kind {
+	case "col":
+		column := strings.TrimSpace(arg)
+		if index, err := strconv.Atoi(column); err == nil && index >= 0 {
+			return placeholder{kind: kind, column: index}, nil
+		}
+		index, ok := c.columns[normalizeColumnName(column)]
+		if !ok {
+			return placeholder{}, fmt.Errorf("模板占位符 ${%s} 引用的列不存在", expr)
+		}
+		return placeholder{kind: kind, arg: column, column: index}, nil
+	case "param":
+		if arg == "" {
+			return placeholder{}, fmt.Errorf("模板占位符 ${%s} 缺少参数名", expr)
+		}
+		return placeholder{kind: kind, arg: arg}, nil
+	case "jsonParam", "ip", "row", "uuid":
+		return placeholder{kind: kind}, nil
+	case "now":
+		if arg == "" {
+			arg = defaultNowLayout
+		}
+		return placeholder{kind: kind, arg: arg}, nil
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return placeholder{}, fmt.Errorf("模板占位符 ${%s} 引用的环境变量未设置", expr)
+		}
+		return placeholder{kind: "value", arg: value}, nil
+	case "var":
+		name := strings.TrimSpace(arg)
+		value, ok := c.vars[name]
+		if !ok {
+			return placeholder{}, fmt.Errorf("模板占位符 ${%s} 引用的变量在当前环境中未定义", expr)
+		}
+		return placeholder{kind: "value", arg: value, secret: c.secretVars[name]}, nil
+	case "secret":
+		value, err := lookupSecret(c.secrets, strings.TrimSpace(arg))
+		if err != nil {
+			return placeholder{}, fmt.Errorf("模板占位符 ${%s} %v", expr, err)
+		}
+		return placeholder{kind: "value", arg: value, secret: true}, nil
+	}
+	return placeholder{}, fmt.Errorf("不支持的模板占位符: ${%s}", expr)
+}
+
+// urlTemplate 编译后的URL模板，路径和查询串中的占位符分别按各自规则转义，
+// 协议和主机部分（可使用 ${ip}）不转义
+type urlTemplate struct {
+	origin *textTemplate
+	path   *textTemplate
+	query  *textTemplate // 不含 '?'，没有查询串时为空
+}
+
+// 编译URL模板
+func (c *templateCompiler) compileURL(rawURL string) (*urlTemplate, error) {
+	rest, queryPart, hasQuery := strings.Cut(strings.TrimSpace(rawURL), "?")
+	originPart, pathPart := "", rest
+	if i := strings.Index(rest, "://"); i >= 0 {
+		originPart, pathPart = rest, ""
+		if j := strings.Index(rest[i+3:], "/"); j >= 0 {
+			originPart, pathPart = rest[:i+3+j], rest[i+3+j:]
+		}
+	} else if strings.HasPrefix(rest, "${") {
+		// 以占位符开头（如 ${var:baseUrl}/orders）时，开头的占位符是协议和主机，不转义
+		if i := strings.Index(rest, "}"); i >= 0 {
+			originPart, pathPart = rest, ""
+			if j := strings.Index(rest[i:], "/"); j >= 0 {
+				originPart, pathPart = rest[:i+j], rest[i+j:]
+			}
+		}
+	}
+
+	t := &urlTemplate{}
+	var err error
+	if t.origin, err = c.compileText(originPart); err != nil {
+		return nil, fmt.Errorf("请求地址模板解析失败: %v", err)
+	}
+	if t.path, err = c.compileText(pathPart); err != nil {
+		return nil, fmt.Errorf("请求地址模板解析失败: %v", err)
+	}
+	// 路径中的环境变量、运行变量和密钥常用来配置路径前缀（如 api/v1），按原样拼接，
+	// 行数据和参数的值仍按路径段转义
+	t.path.escape = url.PathEscape
+	t.path.rawValues = true
+	if hasQuery {
+		if t.query, err = c.compileText(queryPart); err != nil {
+			return nil, fmt.Errorf("请求地址模板解析失败: %v", err)
+		}
+		t.query.escape = url.QueryEscape
+	}
+	return t, nil
+}
+
+func (t *urlTemplate) render(vars *templateVars) (string, error) {
+	origin, err := t.origin.render(vars)
+	if err != nil {
+		return "", err
+	}
+	path, err := t.path.render(vars)
+	if err != nil {
+		return "", err
+	}
+	if t.query == nil {
+		return origin + path, nil
+	}
+	query, err := t.query.render(vars)
+	if err != nil {
+		return "", err
+	}
+	return origin + path + "?" + query, nil
+}
+
+// bodyTemplate 编译后的请求体模板
+type bodyTemplate interface {
+	render(vars *templateVars) ([]byte, error)
+}
+
+// textBody 表单和纯文本请求体，整体按字符串模板渲染
+type textBody struct {
+	text *textTemplate
+}
+
+func (b textBody) render(vars *templateVars) ([]byte, error) {
+	text, err := b.text.render(vars)
+	return []byte(text), err
+}
+
+// 按请求体格式编译模板：JSON 逐节点编译，表单中的占位符值按查询参数转义，纯文本原样输出
+func (c *templateCompiler) compileBody(format, body string) (bodyTemplate, error) {
+	if format == BodyFormatJSON {
+		return c.compileJSON(body)
+	}
+	text, err := c.compileText(body)
+	if err != nil {
+		return nil, fmt.Errorf("请求体模板解析失败: %v", err)
+	}
+	if format == BodyFormatForm {
+		text.escape = url.QueryEscape
+	}
+	return textBody{text: text}, nil
+}
+
+// headerTemplate 编译后的请求头，只有值支持占位符
+type headerTemplate struct {
+	name  string
+	value *textTemplate
+}
+
+func (c *templateCompiler) compileHeaders(headers []Header) ([]headerTemplate, error) {
+	compiled := make([]headerTemplate, 0, len(headers))
+	for _, header := range headers {
+		value, err := c.compileText(header.Value)
+		if err != nil {
+			return nil, fmt.Errorf("请求头 %s 模板解析失败: %v", header.Name, err)
+		}
+		compiled = append(compiled, headerTemplate{name: strings.TrimSpace(header.Name), value: value})
+	}
+	return compiled, nil
+}
+
+// jsonNode 编译后的JSON模板节点
+type jsonNode struct {
+	raw    json.RawMessage // 数字、布尔、null 原样输出
+	text   *textTemplate   // 字符串值
+	keys   []*textTemplate // 对象的键，保持模板中的顺序
+	values []*jsonNode     // 对象或数组的元素
+	array  bool
+	object bool
+}
+
+// jsonTemplate 编译后的请求体模板，每次执行只解析一次
+type jsonTemplate struct {
+	root *jsonNode
+}
+
+// 编译JSON请求体模板，键和字符串值中都可以使用占位符
+func (c *templateCompiler) compileJSON(body string) (*jsonTemplate, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	root, err := c.compileJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("请求体模板解析失败: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("请求体模板解析失败: JSON之后存在多余内容")
+	}
+	applyLegacyPlaceholders(root)
+	return &jsonTemplate{root: root}, nil
+}
+
+func (c *templateCompiler) compileJSONValue(decoder *json.Decoder) (*jsonNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		node := &jsonNode{object: value == '{', array: value == '['}
+		for decoder.More() {
+			if node.object {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, err := c.compileText(keyToken.(string))
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key)
+			}
+			child, err := c.compileJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, child)
+		}
+		// 读取结束符
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		text, err := c.compileText(value)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{text: text}, nil
+	default:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{raw: raw}, nil
+	}
+}
+
+// 兼容旧模板：顶层的 ipPort / jsonParam 为固定值（如 "%s"）或非字符串值时，与原实现一样
+// 分别替换为选中的IP和生成的参数；已使用占位符时保持不变。模板中没有这两个键时不添加，
+// 发往其他接口的请求体保持原样
+func applyLegacyPlaceholders(root *jsonNode) {
+	if !root.object {
+		return
+	}
+	legacy := map[string]string{"ipPort": "ip", "jsonParam": "jsonParam"}
+	for i, key := range root.keys {
+		if len(key.parts) != 1 || !key.isLiteral() {
+			continue
+		}
+		kind, ok := legacy[key.parts[0].arg]
+		if !ok {
+			continue
+		}
+		if node := root.values[i]; node.text == nil || node.text.isLiteral() {
+			root.values[i] = &jsonNode{text: &textTemplate{parts: []placeholder{{kind: kind}}}}
+		}
+	}
+}
+
+func (t *jsonTemplate) render(vars *templateVars) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.root.render(&buf, vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *jsonNode) render(buf *bytes.Buffer, vars *templateVars) error {
+	switch {
+	case n.object, n.array:
+		start, end := byte('['), byte(']')
+		if n.object {
+			start, end = '{', '}'
+		}
+		buf.WriteByte(start)
+		for i, value := range n.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if n.object {
+				if err := writeJSONString(buf, n.keys[i], vars); err != nil {
+					return err
+				}
+				buf.WriteByte(':')
+			}
+			if err := value.render(buf, vars); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(end)
+	case n.text != nil:
+		return writeJSONString(buf, n.text, vars)
+	default:
+		buf.Write(n.raw)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, text *textTemplate, vars *templateVars) error {
+	value, err := text.render(vars)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(encoded)
+	return nil
+}
+
+// 生成随机UUID（版本4）
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
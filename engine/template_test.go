@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegacyPlaceholders(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"fixed values", `{"ipPort":"%s","jsonParam":"","a":1}`, `{"ipPort":"1.2.3.4:80","jsonParam":"{\"id\":1}","a":1}`},
+		{"plain REST body unchanged", `{"a":"${col:id}","b":[1,"x"]}`, `{"a":"7","b":[1,"x"]}`},
+		{"only present key filled", `{"ipPort":"","a":1}`, `{"ipPort":"1.2.3.4:80","a":1}`},
+		{"placeholders kept", `{"ipPort":"http://${ip}","jsonParam":"${jsonParam}"}`, `{"ipPort":"http://1.2.3.4:80","jsonParam":"{\"id\":1}"}`},
+		{"non-string values", `{"ipPort":null,"jsonParam":{"x":1}}`, `{"ipPort":"1.2.3.4:80","jsonParam":"{\"id\":1}"}`},
+		{"array body", `["${ip}"]`, `["1.2.3.4:80"]`},
+	}
+	compiler := newTemplateCompiler([]string{"id"}, nil, nil)
+	vars := &templateVars{row: []string{"7"}, ip: "1.2.3.4:80", params: []byte(`{"id":1}`)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := compiler.compileJSON(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.render(vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("render = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONTemplateEscapesValues(t *testing.T) {
+	compiler := newTemplateCompiler([]string{"id", "name"}, nil, nil)
+	tmpl, err := compiler.compileJSON(`{"${col:id}":"${col:name}","list":[1,"${row}",true,null]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.render(&templateVars{row: []string{"k", `say "hi"\n`}, rowIndex: 2, params: []byte("{}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"k":"say \"hi\"\\n","list":[1,"2",true,null]}`
+	if string(got) != want {
+		t.Errorf("render = %s, want %s", got, want)
+	}
+}
+
+func TestCompileTemplateErrors(t *testing.T) {
+	compiler := newTemplateCompiler([]string{"id"}, map[string]string{}, nil)
+	tests := []struct {
+		tmpl    string
+		wantErr string
+	}{
+		{"${col:missing}", "引用的列不存在"},
+		{"${param:}", "缺少参数名"},
+		{"${var:missing}", "在当前环境中未定义"},
+		{"${env:HTTP_TOOL_TEST_UNSET}", "环境变量未设置"},
+		{"${secret:token}", "需要先解锁保险库"},
+		{"${unknown}", "不支持的模板占位符"},
+		{"a ${col:id", "占位符未闭合"},
+	}
+	for _, tt := range tests {
+		_, err := compiler.compileText(tt.tmpl)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("compileText(%q) error = %v, want %q", tt.tmpl, err, tt.wantErr)
+		}
+	}
+	if _, err := compiler.compileJSON(`{"a":1} x`); err == nil {
+		t.Error("trailing content after JSON accepted")
+	}
+}
+
+func TestRenderURL(t *testing.T) {
+	t.Setenv("HTTP_TOOL_TEST_ENV", "e v")
+	compiler := newTemplateCompiler([]string{"id", "Name"}, nil, nil)
+	vars := &templateVars{
+		row:      []string{"1 2", "a&b/c"},
+		rowIndex: 3,
+		ip:       "10.0.0.1:80",
+		params:   []byte(`{"name":"x y","n":5}`),
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"http://${ip}/o/${col:name}?q=${col:0}&n=${param:n}", "http://10.0.0.1:80/o/a&b%2Fc?q=1+2&n=5"},
+		{"http://h/a%20b?x=1&y=${row}", "http://h/a%20b?x=1&y=3"},
+		{"http://h/o?e=${env:HTTP_TOOL_TEST_ENV}", "http://h/o?e=e+v"},
+		{"http://${ip}", "http://10.0.0.1:80"},
+	}
+	for _, tt := range tests {
+		tmpl, err := compiler.compileURL(tt.tmpl)
+		if err != nil {
+			t.Fatalf("compileURL(%q): %v", tt.tmpl, err)
+		}
+		got, err := tmpl.render(vars)
+		if err != nil {
+			t.Fatalf("render(%q): %v", tt.tmpl, err)
+		}
+		if got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBodyAndHeaders(t *testing.T) {
+	compiler := newTemplateCompiler([]string{"id", "Name"}, nil, nil)
+	vars := &templateVars{
+		row:    []string{"1 2", "a&b/c"},
+		params: []byte(`{"name":"x y","obj":{"k":[1]}}`),
+	}
+	tests := []struct {
+		format string
+		tmpl   string
+		want   string
+	}{
+		{BodyFormatForm, "name=${param:name}&c=${col:Name}", "name=x+y&c=a%26b%2Fc"},
+		{BodyFormatForm, "raw=a%2Bb", "raw=a%2Bb"},
+		{BodyFormatText, "${col:name} ${param:obj} $${literal}", `a&b/c {"k":[1]} ${literal}`},
+	}
+	for _, tt := range tests {
+		body, err := compiler.compileBody(tt.format, tt.tmpl)
+		if err != nil {
+			t.Fatalf("compileBody(%s, %q): %v", tt.format, tt.tmpl, err)
+		}
+		got, err := body.render(vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s body %q = %q, want %q", tt.format, tt.tmpl, got, tt.want)
+		}
+	}
+
+	headers, err := compiler.compileHeaders([]Header{{Name: " X-Name ", Value: "v=${col:name}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := headers[0].value.render(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers[0].name != "X-Name" || value != "v=a&b/c" {
+		t.Errorf("header = %q: %q", headers[0].name, value)
+	}
+}
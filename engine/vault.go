@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 保险库文件格式版本
+const vaultVersion = 1
+
+// scrypt 参数，约需 32MB 内存，解锁一次在 100ms 左右
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	vaultKeySize = 32 // AES-256
+	vaultSalt    = 16
+)
+
+// 打开保险库时接受的 scrypt 参数范围。参数来自文件，在解密前就会用于派生密钥，
+// 超出范围时直接拒绝，避免被篡改的文件耗尽内存或 CPU，或以过弱的参数解锁
+const (
+	scryptMinN = 1 << 14
+	scryptMaxN = 1 << 20 // 1GB 内存
+	scryptMaxR = 16
+	scryptMaxP = 4
+)
+
+// VaultFileName 应用数据目录中保险库文件的名称
+const VaultFileName = "secrets.vault"
+
+// ErrVaultPassphrase 密码错误或保险库文件被篡改
+var ErrVaultPassphrase = errors.New("保险库密码错误或文件已损坏")
+
+// 保险库文件内容：密钥由密码经 scrypt 派生，密钥表整体以 AES-GCM 加密，
+// 版本和派生参数作为附加数据参与认证，任何字段被修改都无法解密
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (f *vaultFile) additionalData() []byte {
+	return []byte(fmt.Sprintf("http-gui-tool vault v%d %s n=%d r=%d p=%d", f.Version, f.KDF, f.N, f.R, f.P))
+}
+
+// Vault 本地加密保存的密钥表，配置中以 ${secret:名称} 引用，保存的配置文件中不含明文凭据
+type Vault struct {
+	path    string
+	file    vaultFile
+	key     []byte
+	secrets map[string]string
+}
+
+// VaultExists 保险库文件是否已创建
+func VaultExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// OpenVault 用密码解锁保险库，文件不存在时创建一个空的保险库（调用 Save 后才写入文件）
+func OpenVault(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("保险库密码不能为空")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		v := &Vault{path: path, secrets: make(map[string]string)}
+		return v, v.derive(passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取保险库失败: %v", err)
+	}
+
+	v := &Vault{path: path}
+	if err := json.Unmarshal(data, &v.file); err != nil {
+		return nil, fmt.Errorf("保险库文件格式错误: %v", err)
+	}
+	if v.file.Version != vaultVersion || v.file.KDF != "scrypt" {
+		return nil, fmt.Errorf("不支持的保险库版本: %d (%s)", v.file.Version, v.file.KDF)
+	}
+	if err := v.file.checkParams(); err != nil {
+		return nil, err
+	}
+	if v.key, err = scrypt.Key([]byte(passphrase), v.file.Salt, v.file.N, v.file.R, v.file.P, vaultKeySize); err != nil {
+		return nil, fmt.Errorf("保险库参数错误: %v", err)
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, v.file.Nonce, v.file.Data, v.file.additionalData())
+	if err != nil {
+		return nil, ErrVaultPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, ErrVaultPassphrase
+	}
+	if v.secrets == nil {
+		v.secrets = make(map[string]string)
+	}
+	return v, nil
+}
+
+// 检查文件中的派生参数是否在允许范围内
+func (f *vaultFile) checkParams() error {
+	if f.N < scryptMinN || f.N > scryptMaxN || f.N&(f.N-1) != 0 ||
+		f.R < 1 || f.R > scryptMaxR || f.P < 1 || f.P > scryptMaxP || len(f.Salt) < vaultSalt {
+		return fmt.Errorf("保险库参数错误: n=%d r=%d p=%d", f.N, f.R, f.P)
+	}
+	return nil
+}
+
+// 用新的盐派生密钥，创建保险库和修改密码时调用
+func (v *Vault) derive(passphrase string) error {
+	salt := make([]byte, vaultSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, vaultKeySize)
+	if err != nil {
+		return err
+	}
+	v.file = vaultFile{Version: vaultVersion, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: salt}
+	v.key = key
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ChangePassphrase 修改密码，调用 Save 后生效
+func (v *Vault) ChangePassphrase(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("保险库密码不能为空")
+	}
+	return v.derive(passphrase)
+}
+
+// Save 加密后写入文件，先写临时文件再替换，避免写入中断损坏原文件
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	v.file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(v.file.Nonce); err != nil {
+		return err
+	}
+	v.file.Data = gcm.Seal(nil, v.file.Nonce, plain, v.file.additionalData())
+	data, err := json.MarshalIndent(&v.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return fmt.Errorf("创建保险库目录失败: %v", err)
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入保险库失败: %v", err)
+	}
+	if err := os.Rename(tmp, v.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入保险库失败: %v", err)
+	}
+	return nil
+}
+
+// Names 保险库中的密钥名称，按名称排序
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get 取出密钥的值
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+	return value, ok
+}
+
+// Set 添加或更新密钥，调用 Save 后写入文件
+func (v *Vault) Set(name, value string) error {
+	if err := validateVarName(name); err != nil {
+		return fmt.Errorf("密钥%v", err)
+	}
+	v.secrets[name] = value
+	return nil
+}
+
+// Delete 删除密钥，调用 Save 后写入文件
+func (v *Vault) Delete(name string) {
+	delete(v.secrets, name)
+}
+
+// Secrets 全部密钥的副本，用于设置 Config.Secrets
+func (v *Vault) Secrets() map[string]string {
+	secrets := make(map[string]string, len(v.secrets))
+	for name, value := range v.secrets {
+		secrets[name] = value
+	}
+	return secrets
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", VaultFileName)
+	if VaultExists(path) {
+		t.Fatal("vault exists before save")
+	}
+	v, err := OpenVault(path, "pass1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"cookie": "sid=1; uid=2", "token": "a+b&c", "empty": ""} {
+		if err := v.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Set("bad name", "x"); err == nil {
+		t.Error("invalid secret name accepted")
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("sid=1")) || bytes.Contains(data, []byte("a+b&c")) {
+		t.Fatal("vault file contains plain text secrets")
+	}
+
+	reopened, err := OpenVault(path, "pass1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reopened.Secrets(), v.Secrets()) {
+		t.Errorf("secrets after reopen = %v, want %v", reopened.Secrets(), v.Secrets())
+	}
+	if want := []string{"cookie", "empty", "token"}; !reflect.DeepEqual(reopened.Names(), want) {
+		t.Errorf("Names = %v, want %v", reopened.Names(), want)
+	}
+
+	// 修改密码后旧密码失效
+	reopened.Delete("empty")
+	if err := reopened.ChangePassphrase("pass2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenVault(path, "pass1"); !errors.Is(err, ErrVaultPassphrase) {
+		t.Errorf("old passphrase error = %v, want ErrVaultPassphrase", err)
+	}
+	v2, err := OpenVault(path, "pass2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v2.Get("empty"); ok {
+		t.Error("deleted secret still present")
+	}
+	if value, _ := v2.Get("token"); value != "a+b&c" {
+		t.Errorf("token = %q", value)
+	}
+}
+
+// 派生参数被篡改时无法解密，不会用被削弱的参数打开
+func TestVaultRejectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), VaultFileName)
+	v, err := OpenVault(path, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Set("token", "x")
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	var file vaultFile
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Data[0] ^= 1
+	data, _ = json.Marshal(&file)
+	os.WriteFile(path, data, 0o600)
+	if _, err := OpenVault(path, "pass"); !errors.Is(err, ErrVaultPassphrase) {
+		t.Errorf("tampered vault error = %v, want ErrVaultPassphrase", err)
+	}
+	if _, err := OpenVault(path, ""); err == nil {
+		t.Error("empty passphrase accepted")
+	}
+}
+
+// 派生参数超出范围时在派生密钥前拒绝
+func TestVaultRejectsUnsafeParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), VaultFileName)
+	v, err := OpenVault(path, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	tests := []struct {
+		name   string
+		modify func(f *vaultFile)
+	}{
+		{"huge n", func(f *vaultFile) { f.N = 1 << 30 }},
+		{"weak n", func(f *vaultFile) { f.N = 2 }},
+		{"n not power of two", func(f *vaultFile) { f.N = scryptN + 1 }},
+		{"huge r", func(f *vaultFile) { f.R = 1 << 20 }},
+		{"huge p", func(f *vaultFile) { f.P = 1 << 20 }},
+		{"zero p", func(f *vaultFile) { f.P = 0 }},
+		{"short salt", func(f *vaultFile) { f.Salt = f.Salt[:4] }},
+	}
+	for _, tt := range tests {
+		var file vaultFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Fatal(err)
+		}
+		tt.modify(&file)
+		modified, _ := json.Marshal(&file)
+		os.WriteFile(path, modified, 0o600)
+		_, err := OpenVault(path, "pass")
+		if err == nil || errors.Is(err, ErrVaultPassphrase) {
+			t.Errorf("%s: error = %v, want parameter error", tt.name, err)
+		}
+	}
+}
+
+func TestFindAndReplaceCredentials(t *testing.T) {
+	config := &Config{
+		URL:        "http://h/api?user=bob&access_token=abc",
+		Cookie:     "sid=1",
+		BodyFormat: BodyFormatJSON,
+		BodyTemp:   `{"name":"x","password":"p\"w","token":"${col:t}"}`,
+		Headers: []Header{
+			{Name: "Authorization", Value: "Bearer abc"},
+			{Name: "Accept", Value: "application/json"},
+		},
+		Environments: []Environment{{Name: "prod", Vars: map[string]string{"apiKey": "abc", "host": "h"}}},
+	}
+	existing := map[string]string{"cookie": "other"}
+	creds := FindCredentials(config, existing)
+	got := make(map[string]string)
+	for _, cred := range creds {
+		got[cred.Field] = cred.Name + "=" + cred.Value
+	}
+	want := map[string]string{
+		"Cookie":            "cookie_2=sid=1",
+		"请求头 Authorization": "authorization=Bearer abc",
+		"请求地址 access_token": "access_token=abc",
+		"请求体 password":      "password=p\"w",
+		"环境 prod 变量 apiKey": "prod_apikey=abc",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindCredentials = %v, want %v", got, want)
+	}
+	if config.Cookie != "sid=1" {
+		t.Fatal("FindCredentials modified the config")
+	}
+
+	envVars := config.Environments[0].Vars
+	ReplaceCredentials(config, creds)
+	if config.Cookie != "${secret:cookie_2}" ||
+		config.URL != "http://h/api?user=bob&access_token=${secret:access_token}" ||
+		config.BodyTemp != `{"name":"x","password":"${secret:password}","token":"${col:t}"}` ||
+		config.Headers[0].Value != "${secret:authorization}" || config.Headers[1].Value != "application/json" ||
+		config.Environments[0].Vars["apiKey"] != "${secret:prod_apikey}" {
+		t.Errorf("ReplaceCredentials result: %+v", config)
+	}
+	if envVars["apiKey"] != "abc" {
+		t.Error("ReplaceCredentials modified the original environment variables")
+	}
+	if !config.UsesSecrets() {
+		t.Error("UsesSecrets = false after migration")
+	}
+	if creds := FindCredentials(config, nil); len(creds) != 0 {
+		t.Errorf("credentials left after migration: %+v", creds)
+	}
+}
@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 写回工作簿时追加在数据列之后的结果列
+var xlsxResultColumns = []string{"outcome", "status", "latencyMs", "response"}
+
+// xlsxSource Excel 工作簿数据源，一次读入整个工作表
+type xlsxSource struct {
+	path      string
+	sheet     string
+	rows      [][]string
+	sheetRows []int // 各行在工作表中的行号（从1开始），与 rows 一一对应
+	width     int   // 最宽一行的列数，结果列追加在其后
+	next      int
+}
+
+// SheetNames 工作簿中的工作表名称，按工作簿中的顺序
+func SheetNames(path string) ([]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开Excel文件失败: %v", err)
+	}
+	defer f.Close()
+	return f.GetSheetList(), nil
+}
+
+// 读取工作簿中的工作表，sheet 为空时读取第一个工作表，headerRow 为标题行在工作表中的行号，0 时为第1行
+func openXLSXSource(path, sheet string, headerRow int) (*xlsxSource, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开Excel文件失败: %v", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if sheet == "" && len(sheets) > 0 {
+		sheet = sheets[0]
+	}
+	if !contains(sheets, sheet) {
+		return nil, fmt.Errorf("工作表 %q 不存在，可用工作表: %s", sheet, strings.Join(sheets, ", "))
+	}
+	formatted, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作表 %s 失败: %v", sheet, err)
+	}
+	raw, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("读取工作表 %s 失败: %v", sheet, err)
+	}
+
+	if headerRow <= 0 {
+		headerRow = 1
+	}
+	s := &xlsxSource{path: path, sheet: sheet}
+	for i := headerRow - 1; i < len(formatted); i++ {
+		row := make([]string, len(formatted[i]))
+		empty := true
+		for j, value := range formatted[i] {
+			if i < len(raw) && j < len(raw[i]) {
+				value = cellText(value, raw[i][j])
+			}
+			row[j] = value
+			empty = empty && strings.TrimSpace(value) == ""
+		}
+		// 标题行始终保留，数据中的空行忽略
+		if empty && len(s.rows) > 0 {
+			continue
+		}
+		s.rows = append(s.rows, row)
+		s.sheetRows = append(s.sheetRows, i+1)
+		if len(row) > s.width {
+			s.width = len(row)
+		}
+	}
+	return s, nil
+}
+
+// 数字单元格使用原始值，避免长订单号按常规格式显示为科学计数法或丢失位数；
+// 日期、百分比、货币等显示值不是普通数字的单元格保留显示值
+func cellText(formatted, raw string) string {
+	if formatted == raw {
+		return formatted
+	}
+	if _, err := strconv.ParseFloat(strings.TrimSpace(formatted), 64); err != nil {
+		return formatted
+	}
+	number, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return formatted
+	}
+	if strings.ContainsAny(raw, "eE") {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return raw
+}
+
+func (s *xlsxSource) Read() ([]string, error) {
+	if s.next >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := s.rows[s.next]
+	s.next++
+	return row, nil
+}
+
+// 行号为工作表中的实际行号，跳过的空行和标题行之前的行也计入，与 Excel 中看到的一致
+func (s *xlsxSource) lastRowIndex() (int, bool) {
+	if s.next == 0 {
+		return 0, false
+	}
+	return s.sheetRows[s.next-1], true
+}
+
+func (s *xlsxSource) Close() error {
+	return nil
+}
+
+// 写回工作簿时每写入这么多行保存一次，进程异常退出时也能保留大部分结果
+const xlsxSaveBatch = 5000
+
+// xlsxResultSink 把各行结果写回工作簿的副本：在标题行和对应的数据行追加结果列。
+// 结果随到随写入工作簿，不在内存中保留，每写入一批保存一次，关闭时保存剩余的结果
+type xlsxResultSink struct {
+	mu      sync.Mutex
+	src     *xlsxSource
+	path    string
+	file    *excelize.File
+	pending int // 上次保存后写入的行数
+}
+
+// 打开写回工作簿的结果文件，src 必须是 Excel 数据源，结果文件不能覆盖数据文件
+func openWorkbookSink(src Source, path string) (ResultSink, error) {
+	workbook, ok := src.(*xlsxSource)
+	if !ok {
+		return nil, fmt.Errorf("Excel 结果文件需要使用 Excel 数据文件")
+	}
+	if samePath(workbook.path, path) {
+		return nil, fmt.Errorf("结果文件不能与数据文件相同，请另存为副本")
+	}
+	f, err := excelize.OpenFile(workbook.path)
+	if err != nil {
+		return nil, fmt.Errorf("打开Excel文件失败: %v", err)
+	}
+	for i, name := range xlsxResultColumns {
+		if err := setCell(f, workbook.sheet, workbook.width+i+1, workbook.sheetRows[0], name); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	// 先保存一次标题，提前发现结果文件无法写入
+	if err := f.SaveAs(path); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("创建结果文件失败: %v", err)
+	}
+	return &xlsxResultSink{src: workbook, path: path, file: f}, nil
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Write 把结果写入对应行的结果列，结果的行号即工作表中的行号
+func (s *xlsxResultSink) Write(result *Result) error {
+	if result.RowIndex <= s.src.sheetRows[0] {
+		return nil
+	}
+	response := result.Response
+	if response == "" {
+		response = result.Error
+	}
+	values := []interface{}{result.Outcome, result.Status, math.Round(result.LatencyMs*1000) / 1000, response}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, value := range values {
+		if err := setCell(s.file, s.src.sheet, s.src.width+i+1, result.RowIndex, value); err != nil {
+			return err
+		}
+	}
+	s.pending++
+	if s.pending < xlsxSaveBatch {
+		return nil
+	}
+	s.pending = 0
+	return s.save()
+}
+
+func (s *xlsxResultSink) save() error {
+	if err := s.file.SaveAs(s.path); err != nil {
+		return fmt.Errorf("保存Excel结果文件失败: %v", err)
+	}
+	return nil
+}
+
+// Close 保存剩余的结果
+func (s *xlsxResultSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.save()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func setCell(f *excelize.File, sheet string, col, row int, value interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return err
+	}
+	return f.SetCellValue(sheet, cell, value)
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 工作表中有空行和标题行之前的行时，行号与工作表中的行号一致，结果写回对应的行
+func TestXLSXRowIndexMatchesSheet(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "data.xlsx")
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	for cell, value := range map[string]interface{}{
+		"A1": "report title",
+		"A2": "id",
+		"A3": "a",
+		"A5": "b", // 第4行为空行
+		"A8": "c", // 第6、7行为空行
+	} {
+		if err := f.SetCellValue(sheet, cell, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SaveAs(input); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	config := testConfig(server)
+	config.InputFormat = InputFormatXLSX
+	config.HeaderRow = 2
+	config.ResultFile = filepath.Join(dir, "result.xlsx")
+
+	var mu sync.Mutex
+	var rows []int
+	src, err := OpenSource(input, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	summary, err := NewRunner(config, func(event Event) {
+		if event.Type == EventRowSucceeded {
+			mu.Lock()
+			rows = append(rows, event.RowIndex)
+			mu.Unlock()
+		}
+	}).Run(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Success != 3 {
+		t.Fatalf("succeeded = %d, want 3", summary.Success)
+	}
+	sort.Ints(rows)
+	if want := []int{3, 5, 8}; len(rows) != 3 || rows[0] != want[0] || rows[1] != want[1] || rows[2] != want[2] {
+		t.Errorf("row indexes = %v, want %v", rows, want)
+	}
+
+	out, err := excelize.OpenFile(config.ResultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for cell, want := range map[string]string{"B2": "outcome", "B3": ResultSuccess, "B4": "", "B5": ResultSuccess, "B8": ResultSuccess} {
+		got, err := out.GetCellValue(sheet, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("result cell %s = %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -1,16 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"image/color"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,24 +18,12 @@ import (
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	
 	"fyne.io/fyne/v2/widget"
-)
 
-// 自定义HTTP客户端，带连接池和超时设置
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second, // 请求总超时
-	Transport: &http.Transport{
-		MaxIdleConns:        100,              // 最大空闲连接数
-		MaxIdleConnsPerHost: 20,               // 每个主机的最大空闲连接数
-		MaxConnsPerHost:     50,               // 每个主机的最大连接数
-		IdleConnTimeout:     90 * time.Second, // 空闲连接超时
-		TLSHandshakeTimeout: 10 * time.Second, // TLS握手超时
-		DisableCompression:  false,            // 启用压缩
-		ForceAttemptHTTP2:   true,             // 尝试使用HTTP/2
-		DisableKeepAlives:   false,            // 启用连接复用
-	},
-}
+	"http-gui-tool/engine"
+)
 
 // 字符串构建器池，减少内存分配
 var stringBuilderPool = sync.Pool{
@@ -58,15 +43,6 @@ func putStringBuilder(sb *strings.Builder) {
 	stringBuilderPool.Put(sb)
 }
 
-// 参数映射配置
-type ParamMapping struct {
-	CSVColumn    string `json:"csvColumn"`    // CSV列名或索引
-	ParamName    string `json:"paramName"`    // 请求参数名（对象模式）或数组索引（数组模式）
-	ParamType    string `json:"paramType"`    // 参数类型：string, int, float, bool
-	DefaultValue string `json:"defaultValue"` // 默认值
-	ArrayIndex   int    `json:"arrayIndex"`   // 数组模式下的参数位置索引
-}
-
 // 参数映射行UI组件
 type ParamMappingRow struct {
 	CSVColumnEntry    *widget.Entry
@@ -78,32 +54,56 @@ type ParamMappingRow struct {
 	Container         *fyne.Container
 }
 
-// Config 配置结构
-type Config struct {
-	URL           string         `json:"url"`
-	Cookie        string         `json:"cookie"`
-	BodyTemp      string         `json:"bodyTemp"`
-	IPList        []string       `json:"ipList"`
-	QPS           int            `json:"qps"`
-	Workers       int            `json:"workers"`
-	MaxRetries    int            `json:"maxRetries"`
-	ParamMappings []ParamMapping `json:"paramMappings"` // 参数映射配置
-	ParamMode     string         `json:"paramMode"`     // 参数生成模式：object(对象) 或 array(数组)
+// 请求头行UI组件
+type HeaderRow struct {
+	NameEntry    *widget.Entry
+	ValueEntry   *widget.Entry
+	DeleteButton *widget.Button
+	Container    *fyne.Container
+}
+
+// 响应断言行UI组件
+type AssertionRow struct {
+	TypeSelect    *widget.Select
+	FieldEntry    *widget.Entry
+	OpSelect      *widget.Select
+	ValueEntry    *widget.Entry
+	OutcomeSelect *widget.Select
+	DeleteButton  *widget.Button
+	Container     *fyne.Container
+	original      engine.Assertion // 加载时的规则，内容未修改时保留其说明
 }
 
-// RequestTask 请求任务结构
-type RequestTask struct {
-	ParamsJSON []byte
-	RowIndex   int
+// 最近一次执行的配置和未成功的行，用于重新执行或导出失败的行
+type runRecord struct {
+	config  *engine.Config
+	csvPath string
+	failed  []int // 未成功的行号（失败和被取消的行）
+	
+	// 对比模式下响应不一致的行，最多保留前 1000 行
+	mismatches    []*engine.Result
+	mismatchTotal int
 }
 
 // HTTPTool GUI应用结构
 type HTTPTool struct {
 	app    fyne.App
 	window fyne.Window
-	config *Config
+	config *engine.Config
 
+	// 环境切换组件，environments 为编辑中的环境列表
+	envSelect    *widget.Select
+	envVarsEntry *widget.Entry
+	envAddBtn    *widget.Button
+	envDeleteBtn *widget.Button
+	environments []engine.Environment
+	currentEnv   string // 变量编辑框对应的环境
+	
+	// 已解锁的密钥保险库，未解锁时为空
+	vault *engine.Vault
+	
 	// UI组件
+	methodSelect  *widget.Select
 	urlEntry      *widget.Entry
 	cookieEntry   *widget.Entry
 	bodyEntry     *widget.Entry
@@ -111,19 +111,75 @@ type HTTPTool struct {
 	qpsEntry      *widget.Entry
 	workersEntry  *widget.Entry
 	retriesEntry  *widget.Entry
+	profileEntry  *widget.Entry
+	
+	// 目标健康检查组件
+	breakerEntry       *widget.Entry
+	cooldownEntry      *widget.Entry
+	preflightSelect    *widget.Select
+	preflightPathEntry *widget.Entry
+	checkTargetsBtn    *widget.Button
+	balanceSelect      *widget.Select
+	balanceKeyEntry    *widget.Entry
+	compareCheck       *widget.Check
+	compareIgnoreEntry *widget.Entry
 	csvPathEntry  *widget.Entry
 	outputText    *widget.Entry
 	
+	// 数据文件格式：csv、jsonl 或 xlsx，Excel 数据另需工作表和标题行
+	inputFormatSelect *widget.Select
+	encodingSelect    *widget.Select
+	encodingHint      *widget.Label
+	encodingOptions   *fyne.Container
+	sheetEntry        *widget.SelectEntry
+	headerRowEntry    *widget.Entry
+	xlsxOptions       *fyne.Container
+	
+	// 请求体格式和请求头组件
+	bodyFormatSelect *widget.Select
+	headerContainer  *fyne.Container
+	headerList       []*HeaderRow
+	
+	// 响应断言组件
+	assertDefaultSelect *widget.Select
+	assertionContainer  *fyne.Container
+	assertionList       []*AssertionRow
+	
+	// 结果文件组件
+	resultPathEntry    *widget.Entry
+	resultFormatSelect *widget.Select
+	
 	// 控制组件
 	startBtn   *widget.Button
+	resumeBtn  *widget.Button
 	stopBtn    *widget.Button
 	clearBtn   *widget.Button
 	saveBtn    *widget.Button
 	loadBtn    *widget.Button
+	vaultBtn   *widget.Button
+	applyBtn   *widget.Button
+	reportBtn  *widget.Button
+	previewBtn *widget.Button
+	
+	// 失败行重试组件
+	retryBtn        *widget.Button
+	exportFailedBtn *widget.Button
+	diffBtn         *widget.Button
+	
+	// 请求预览的行范围
+	previewRowsEntry *widget.Entry
 	
 	// 状态和进度组件
 	progressBar   *widget.ProgressBar
 	statusLabel   *widget.Label
+	statsLabel    *widget.Label
+	lastReport    *engine.Report // 最近一次执行的统计报告，用于导出
+	lastRun       *runRecord     // 最近一次执行的结果，用于重试失败的行
+	
+	// 实时图表，每秒更新一次
+	qpsChart     *lineChart
+	latencyChart *lineChart
+	errorChart   *lineChart
 	
 	// 参数映射相关组件
 	paramModeSelect       *widget.Select
@@ -134,6 +190,7 @@ type HTTPTool struct {
 	// 运行状态
 	isRunning   bool
 	cancelFunc  context.CancelFunc
+	runner      *engine.Runner // 执行中的Runner，用于调整QPS和并发数
 	mutex       sync.RWMutex
 	
 	// 日志缓冲 - 优化版本
@@ -155,8 +212,10 @@ type HTTPTool struct {
 type progressUpdate struct {
 	processed int
 	total     int
-	success   int
-	errors    int
+	estimated bool // total 为估算值
+	targetQPS float64
+	stats     engine.Report
+	counts    engine.Counts
 }
 
 
@@ -172,6 +231,9 @@ func (h *HTTPTool) showCustomFileDialog() {
 		// 获取文件路径
 		filePath := reader.URI().Path()
 		h.csvPathEntry.SetText(filePath)
+		h.inputFormatSelect.SetSelected(engine.InputFormatFromPath(filePath))
+		h.refreshSheets()
+		h.refreshEncoding()
 		h.appendLog(fmt.Sprintf("Selected file: %s", filePath))
 	}, h.window)
 	
@@ -180,8 +242,34 @@ func (h *HTTPTool) showCustomFileDialog() {
 	fileDialog.Show()
 }
 
+// 选择结果文件的保存位置
+func (h *HTTPTool) showResultFileDialog() {
+	fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		// 只需要路径，文件在执行时重新创建
+		writer.Close()
+		
+		filePath := writer.URI().Path()
+		h.resultPathEntry.SetText(filePath)
+		h.resultFormatSelect.SetSelected(engine.ResultFormatFromPath(filePath))
+	}, h.window)
+	fileDialog.SetFileName("results.csv")
+	
+	fileDialog.Resize(fyne.NewSize(1200, 800))
+	fileDialog.Show()
+}
 
 func main() {
+	// 命令行模式：无需图形界面，适用于cron/CI
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "preview" {
+		os.Exit(runPreview(os.Args[2:]))
+	}
+
 	// 设置中文字体支持
 	os.Setenv("FYNE_FONT", "/Library/Fonts/Arial Unicode.ttf")
 	
@@ -189,7 +277,7 @@ func main() {
 
 	tool := &HTTPTool{
 		app: myApp,
-		config: &Config{
+		config: &engine.Config{
 			URL:        "http://intest-manager.jd.com/api/v1/invokeJsfByCallType",
 			QPS:        25,
 			Workers:    100,
@@ -212,6 +300,19 @@ func (h *HTTPTool) setupUI() {
 	h.urlEntry = widget.NewEntry()
 	h.urlEntry.SetText(h.config.URL)
 	h.urlEntry.MultiLine = false
+	h.urlEntry.SetPlaceHolder("http://host/api/orders/${param:orderId}?name=${col:name}")
+
+	// 环境：同一份配置在测试、预发、生产之间切换
+	h.envVarsEntry = widget.NewEntry()
+	h.envVarsEntry.MultiLine = true
+	h.envVarsEntry.SetPlaceHolder("每行一个 名称=值，如:\nips=6.19.96.149:22000, 6.19.134.55:22000\nalias=o2o-settlement-gray")
+	h.envSelect = widget.NewSelect([]string{envNone}, h.switchEnvironment)
+	h.envAddBtn = widget.NewButton("➕ 新建", h.addEnvironment)
+	h.envDeleteBtn = widget.NewButton("🗑 删除", h.deleteEnvironment)
+	h.envSelect.SetSelected(envNone)
+
+	h.methodSelect = widget.NewSelect(engine.Methods, nil)
+	h.methodSelect.SetSelected(h.config.RequestMethod())
 
 	h.cookieEntry = widget.NewEntry()
 	h.cookieEntry.MultiLine = true
@@ -222,10 +323,51 @@ func (h *HTTPTool) setupUI() {
 	h.bodyEntry.Wrapping = fyne.TextWrapWord
 	h.bodyEntry.SetText(`{"key":"6053218425615928","name":"新建JSF","type":"InterfacePage","interfaceType":"JSF","active":true,"inputParamType":"java.lang.String,java.lang.Long","interfaceName":"com.jd.o2o.settlement.BackDoorInnerService","alias":"o2o-settlement-gray","method":"recalculateSettleOrderAmount","ipPort":"%s","token":"","callType":0,"jsonParam":"${jsonParam}","serialization":"msgpack","serializerFeature":"JSON","clientType":"generic","isForceBot":0,"eoneEnv":"","overtime":"","traced":false,"mockType":0,"matchType":"0","compareRuleInfo":{"presets":[],"compareScript":[]},"lineId":23572}`)
 
+	h.bodyFormatSelect = widget.NewSelect(engine.BodyFormats, nil)
+	h.bodyFormatSelect.SetSelected(h.config.RequestBodyFormat())
+
+	// 初始化请求头容器，内置的JSF请求头只作为预设按需填入
+	h.headerContainer = container.NewVBox()
+	h.refreshHeaderContainer()
+
+	// 初始化响应断言，默认规则与原先的判断逻辑一致
+	h.assertDefaultSelect = widget.NewSelect(engine.Outcomes, nil)
+	h.assertDefaultSelect.SetSelected(engine.OutcomeSuccess)
+	h.assertionContainer = container.NewVBox()
+	h.setAssertions(engine.DefaultAssertions())
+
 	h.ipListEntry = widget.NewEntry()
 	h.ipListEntry.MultiLine = true
 	h.ipListEntry.SetText("6.19.96.149:22000\n6.19.134.55:22000\n6.40.32.10:22000\n11.63.86.240:22000\n11.134.9.63:22000")
 
+	// 熔断和预检，默认连续失败5次熔断、10秒后探测
+	h.breakerEntry = widget.NewEntry()
+	h.breakerEntry.SetText("5")
+	h.cooldownEntry = widget.NewEntry()
+	h.cooldownEntry.SetText("10")
+	h.preflightSelect = widget.NewSelect([]string{preflightOff, engine.PreflightTCP, engine.PreflightHTTP}, nil)
+	h.preflightSelect.SetSelected(preflightOff)
+	h.preflightPathEntry = widget.NewEntry()
+	h.preflightPathEntry.SetPlaceHolder("http 预检路径，如 /health")
+	h.checkTargetsBtn = widget.NewButton("🩺 检查目标", h.checkTargets)
+	
+	// 负载均衡策略，hash 策略需要指定列
+	h.balanceKeyEntry = widget.NewEntry()
+	h.balanceKeyEntry.SetPlaceHolder("hash 使用的列名或索引")
+	h.balanceSelect = widget.NewSelect(engine.Balances, func(strategy string) {
+		if strategy == engine.BalanceHash {
+			h.balanceKeyEntry.Enable()
+		} else {
+			h.balanceKeyEntry.Disable()
+		}
+	})
+	h.balanceSelect.SetSelected(engine.BalanceRoundRobin)
+	
+	// 对比模式：每行发送到所有目标，比较前忽略时间戳、traceId 等每次都不同的字段
+	h.compareIgnoreEntry = widget.NewEntry()
+	h.compareIgnoreEntry.SetPlaceHolder("逗号分隔，如 traceId, timestamp, $.data.list[*].updateTime")
+	h.compareCheck = widget.NewCheck("对比模式：每行发送到所有目标并比较响应（第一个目标为基准）", nil)
+
 	h.qpsEntry = widget.NewEntry()
 	h.qpsEntry.SetText("25")
 
@@ -235,8 +377,46 @@ func (h *HTTPTool) setupUI() {
 	h.retriesEntry = widget.NewEntry()
 	h.retriesEntry.SetText("3")
 
+	h.profileEntry = widget.NewEntry()
+	h.profileEntry.MultiLine = true
+	h.profileEntry.SetPlaceHolder("留空则直接按QPS执行。每行一个阶段，如:\n1-25 60  (60秒内从1爬升到25)\n25 300  (保持25持续300秒)")
+
 	h.csvPathEntry = widget.NewEntry()
-	h.csvPathEntry.SetPlaceHolder("Select CSV file path...")
+	h.csvPathEntry.SetPlaceHolder("Select CSV or JSONL file path...")
+	h.sheetEntry = widget.NewSelectEntry(nil)
+	h.sheetEntry.SetPlaceHolder("第一个工作表")
+	h.headerRowEntry = widget.NewEntry()
+	h.headerRowEntry.SetText("1")
+	h.xlsxOptions = container.NewGridWithColumns(4,
+		widget.NewLabel("工作表:"), h.sheetEntry,
+		widget.NewLabel("标题行:"), h.headerRowEntry,
+	)
+	// 中文 Windows 上 Excel 导出的 CSV 通常是 GBK/GB18030 或带 BOM 的 UTF-8，自动检测不准时手动指定
+	h.encodingHint = widget.NewLabel("")
+	h.encodingSelect = widget.NewSelect(engine.Encodings, func(string) {
+		h.refreshEncoding()
+	})
+	h.encodingSelect.SetSelected(engine.EncodingAuto)
+	h.encodingOptions = container.NewGridWithColumns(3,
+		widget.NewLabel("文件编码:"), h.encodingSelect, h.encodingHint,
+	)
+	h.inputFormatSelect = widget.NewSelect(engine.InputFormats, func(format string) {
+		if format == engine.InputFormatXLSX {
+			h.xlsxOptions.Show()
+			h.encodingOptions.Hide()
+			h.refreshSheets()
+		} else {
+			h.xlsxOptions.Hide()
+			h.encodingOptions.Show()
+			h.refreshEncoding()
+		}
+	})
+	h.inputFormatSelect.SetSelected(engine.InputFormatCSV)
+
+	h.resultPathEntry = widget.NewEntry()
+	h.resultPathEntry.SetPlaceHolder("结果文件路径（可选，留空不输出）")
+	h.resultFormatSelect = widget.NewSelect([]string{engine.ResultFormatCSV, engine.ResultFormatJSONL, engine.ResultFormatXLSX}, nil)
+	h.resultFormatSelect.SetSelected(engine.ResultFormatCSV)
 	
 	// 初始化参数映射容器
 	h.paramMappingContainer = container.NewVBox()
@@ -244,7 +424,7 @@ func (h *HTTPTool) setupUI() {
 	
 	// 初始化参数模式选择器
 	h.paramModeSelect = widget.NewSelect(
-		[]string{"object", "array"},
+		engine.ParamModes,
 		func(selected string) {
 			h.config.ParamMode = selected
 			h.refreshParamMappingContainer() // 刷新界面以显示不同模式的字段
@@ -285,11 +465,15 @@ func (h *HTTPTool) setupUI() {
 	h.progressBar = widget.NewProgressBar()
 	h.progressBar.Hide() // 初始隐藏
 	h.statusLabel = widget.NewLabel("就绪")
+	h.statsLabel = widget.NewLabel("")
+	h.setupCharts()
 
 	// 创建按钮
 	h.startBtn = widget.NewButton("▶ 开始执行", h.startExecution)
 	h.startBtn.Importance = widget.HighImportance
 	
+	h.resumeBtn = widget.NewButton("⏯ 继续执行", h.resumeExecution)
+	
 	h.stopBtn = widget.NewButton("⏹ 停止执行", h.stopExecution)
 	h.stopBtn.Importance = widget.DangerImportance
 	h.stopBtn.Disable()
@@ -301,11 +485,35 @@ func (h *HTTPTool) setupUI() {
 	
 	h.saveBtn = widget.NewButton("💾 保存配置", h.saveConfig)
 	h.loadBtn = widget.NewButton("📁 加载配置", h.loadConfigFromFile)
+	h.vaultBtn = widget.NewButton("🔐 保险库", h.showVault)
+	
+	h.applyBtn = widget.NewButton("⚡ 应用到执行中", h.applyRunningLimits)
+	h.applyBtn.Disable()
+	
+	h.reportBtn = widget.NewButton("📊 导出报告", h.exportReport)
+	h.reportBtn.Disable()
+	
+	h.retryBtn = widget.NewButton("🔁 重试失败行", h.retryFailedRows)
+	h.retryBtn.Disable()
+	
+	h.exportFailedBtn = widget.NewButton("📤 导出失败行", h.exportFailedRows)
+	h.exportFailedBtn.Disable()
+	
+	h.diffBtn = widget.NewButton("🔀 查看差异", h.showDiffs)
+	h.diffBtn.Disable()
+	
+	h.previewBtn = widget.NewButton("🔍 预览请求", h.previewRequests)
+	h.previewRowsEntry = widget.NewEntry()
+	h.previewRowsEntry.SetText("10")
+	h.previewRowsEntry.SetPlaceHolder("10 或 20-30")
 
 	// 文件选择按钮
 	csvSelectBtn := widget.NewButton("📂 选择文件", func() {
 		h.showCustomFileDialog()
 	})
+	resultSelectBtn := widget.NewButton("📂 保存位置", func() {
+		h.showResultFileDialog()
+	})
 
 	// 设置输入字段高度
 	h.cookieEntry.Resize(fyne.NewSize(0, 80))
@@ -319,37 +527,77 @@ func (h *HTTPTool) setupUI() {
 
 	// 布局
 	configForm := container.NewVBox(
+		widget.NewCard("🌍 环境", "", container.NewVBox(
+			container.NewBorder(nil, nil, widget.NewLabel("当前环境:"),
+				container.NewHBox(h.envAddBtn, h.envDeleteBtn),
+				h.envSelect),
+			widget.NewLabel("环境变量 (地址、Cookie、请求头、请求体和IP列表中用 ${var:名称} 引用):"),
+			h.envVarsEntry,
+		)),
+		
 		widget.NewCard("🌐 基础配置", "", container.NewVBox(
-			widget.NewLabel("请求地址:"),
-			h.urlEntry,
-			widget.NewLabel("Cookie:"),
+			widget.NewLabel("请求方法和地址:"),
+			container.NewBorder(nil, nil, h.methodSelect, nil, h.urlEntry),
+			widget.NewLabel("Cookie (可用 ${secret:名称} 引用保险库中的密钥):"),
 			h.cookieEntry,
 		)),
 		
 		widget.NewCard("📝 请求模板", "", container.NewVBox(
-			widget.NewLabel("请求体模板 (payLoad):"),
+			container.NewBorder(nil, nil, widget.NewLabel("请求体模板 (payLoad):"), h.bodyFormatSelect),
 			h.bodyEntry,
 		)),
 		
+		widget.NewCard("📨 请求头", "", container.NewVBox(
+			widget.NewLabel("值支持占位符，如 ${col:token}；Content-Type 按请求体格式自动设置，也可在此覆盖:"),
+			h.headerContainer,
+		)),
+		
+		widget.NewCard("✅ 响应断言", "", container.NewVBox(
+			widget.NewLabel("按顺序匹配，第一条命中的规则决定结果 (如 jsonpath $.code != 0 → fail):"),
+			h.assertionContainer,
+			container.NewBorder(nil, nil, widget.NewLabel("无规则命中时:"), nil, h.assertDefaultSelect),
+		)),
+		
 		widget.NewCard("🖥 服务器配置", "", container.NewVBox(
-			widget.NewLabel("IP地址列表 (每行一个):"),
+			widget.NewLabel("IP地址列表 (每行一个，可加权重如 6.19.96.149:22000 weight=3):"),
 			h.ipListEntry,
+			container.NewGridWithColumns(4,
+				widget.NewLabel("负载均衡:"), h.balanceSelect, h.balanceKeyEntry, widget.NewLabel(""),
+				widget.NewLabel("连续失败熔断:"), h.breakerEntry, widget.NewLabel("次 (0 为不熔断)"), widget.NewLabel(""),
+				widget.NewLabel("熔断冷却:"), h.cooldownEntry, widget.NewLabel("秒后探测恢复"), widget.NewLabel(""),
+				widget.NewLabel("执行前预检:"), h.preflightSelect, h.preflightPathEntry, h.checkTargetsBtn,
+			),
+			h.compareCheck,
+			container.NewBorder(nil, nil, widget.NewLabel("对比时忽略字段:"), nil, h.compareIgnoreEntry),
 		)),
 		
-		widget.NewCard("⚡ 性能参数", "", container.NewGridWithColumns(3,
-			widget.NewLabel("QPS:"), h.qpsEntry, widget.NewLabel("请求/秒"),
-			widget.NewLabel("并发数:"), h.workersEntry, widget.NewLabel("线程"),
-			widget.NewLabel("重试次数:"), h.retriesEntry, widget.NewLabel("次"),
+		widget.NewCard("⚡ 性能参数", "", container.NewVBox(
+			container.NewGridWithColumns(3,
+				widget.NewLabel("QPS:"), h.qpsEntry, widget.NewLabel("请求/秒"),
+				widget.NewLabel("并发数:"), h.workersEntry, widget.NewLabel("线程"),
+				widget.NewLabel("重试次数:"), h.retriesEntry, widget.NewLabel("次"),
+				widget.NewLabel("执行中调整:"), h.applyBtn, widget.NewLabel("QPS和并发数"),
+			),
+			widget.NewLabel("流量曲线 (可选，全部阶段结束后按上面的QPS执行):"),
+			h.profileEntry,
 		)),
 		
 		widget.NewCard("📊 数据文件", "", container.NewVBox(
-			widget.NewLabel("CSV 数据文件路径:"),
-			container.NewBorder(nil, nil, nil, csvSelectBtn, h.csvPathEntry),
+			widget.NewLabel("数据文件路径 (CSV、Excel，或每行一个JSON对象/数组的 JSONL):"),
+			container.NewBorder(nil, nil, nil,
+				container.NewHBox(h.inputFormatSelect, csvSelectBtn),
+				h.csvPathEntry),
+			h.xlsxOptions,
+			h.encodingOptions,
+			widget.NewLabel("结果文件 (每行状态、耗时、响应；xlsx 为写回数据工作簿的副本):"),
+			container.NewBorder(nil, nil, nil,
+				container.NewHBox(h.resultFormatSelect, resultSelectBtn),
+				h.resultPathEntry),
 		)),
 		
 		widget.NewCard("🔗 参数映射配置", "",
 			container.NewVBox(
-				widget.NewLabel("配置CSV列与请求参数的映射关系:"),
+				widget.NewLabel("配置CSV列（JSONL 为JSON路径）与请求参数的映射关系:"),
 				container.NewGridWithColumns(2,
 					widget.NewLabel("参数生成模式:"),
 					h.paramModeSelect,
@@ -367,15 +615,33 @@ func (h *HTTPTool) setupUI() {
 	// 主要控制按钮
 	mainControlPanel := container.NewHBox(
 		h.startBtn,
+		h.resumeBtn,
 		h.stopBtn,
 		widget.NewSeparator(),
 		h.clearBtn,
+		h.reportBtn,
+	)
+	
+	// 上次执行失败的行
+	failedPanel := container.NewHBox(
+		h.retryBtn,
+		h.exportFailedBtn,
+		h.diffBtn,
+	)
+	
+	// 请求预览：按配置生成指定行的请求，不发送
+	previewPanel := container.NewHBox(
+		h.previewBtn,
+		widget.NewLabel("数据行:"),
+		container.NewGridWrap(fyne.NewSize(110, h.previewRowsEntry.MinSize().Height), h.previewRowsEntry),
+		widget.NewLabel("(N 为前N行，M-N 为第M到N行)"),
 	)
 
 	// 配置管理按钮
 	configPanel := container.NewHBox(
 		h.saveBtn,
 		h.loadBtn,
+		h.vaultBtn,
 	)
 
 	// 状态栏
@@ -389,14 +655,22 @@ func (h *HTTPTool) setupUI() {
 	progressSection := container.NewVBox(
 		h.progressBar,
 		statusBar,
+		h.statsLabel,
 	)
 
 	// 创建一个更大的日志显示区域
 	logCard := widget.NewCard("📋 执行日志", "", container.NewScroll(h.outputText))
 	
+	// 实时图表与日志分页显示
+	chartPanel := container.NewGridWithRows(3, h.qpsChart, h.latencyChart, h.errorChart)
+	bottomTabs := container.NewAppTabs(
+		container.NewTabItem("📋 执行日志", logCard),
+		container.NewTabItem("📈 实时图表", chartPanel),
+	)
+	
 	// 上方控制区域
 	topControlsPanel := container.NewVBox(
-		widget.NewCard("🎮 控制面板", "", mainControlPanel),
+		widget.NewCard("🎮 控制面板", "", container.NewVBox(mainControlPanel, failedPanel, previewPanel)),
 		widget.NewCard("⚙️ 配置管理", "", configPanel),
 		widget.NewCard("📈 执行状态", "", progressSection),
 	)
@@ -406,7 +680,7 @@ func (h *HTTPTool) setupUI() {
 		topControlsPanel, // 顶部：控制面板
 		nil,              // 底部：无
 		nil, nil,         // 左右：无
-		logCard,          // 中心：日志区域（占用剩余所有空间）
+		bottomTabs,       // 中心：日志和图表区域（占用剩余所有空间）
 	)
 
 	leftPanel := container.NewScroll(configForm)
@@ -418,20 +692,179 @@ func (h *HTTPTool) setupUI() {
 }
 
 func (h *HTTPTool) startExecution() {
+	h.runExecution(false)
+}
+
+// 从上次中断的位置继续：跳过已成功的行，重新发送失败和未完成的行
+func (h *HTTPTool) resumeExecution() {
+	h.runExecution(true)
+}
+
+func (h *HTTPTool) runExecution(resume bool) {
+	if h.needsVault(func() { h.runExecution(resume) }) {
+		return
+	}
 	// 验证输入
 	if err := h.validateInputs(); err != nil {
 		dialog.ShowError(err, h.window)
 		h.statusLabel.SetText("配置错误")
 		return
 	}
+	h.launchExecution(h.collectConfig(), h.csvPathEntry.Text, resume, nil)
+}
+
+// 用上次执行的配置重新执行其中未成功的行，行号与上次的日志一致
+func (h *HTTPTool) retryFailedRows() {
+	run := h.lastRun
+	if run == nil || len(run.failed) == 0 {
+		return
+	}
+	h.launchExecution(run.config, run.csvPath, true, run.failed)
+	h.appendLog(fmt.Sprintf("Retrying %d failed rows from the previous run", len(run.failed)))
+}
+
+// 导出上次执行未成功的行，保留原始标题行，可作为新的数据文件
+func (h *HTTPTool) exportFailedRows() {
+	run := h.lastRun
+	if run == nil || len(run.failed) == 0 {
+		return
+	}
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		path := writer.URI().Path()
+		writer.Close()
+
+		src, err := engine.OpenSource(run.csvPath, run.config)
+		if err != nil {
+			dialog.ShowError(err, h.window)
+			return
+		}
+		defer src.Close()
+		written, err := engine.ExportRows(src, run.config.SourceFormat(), path, run.failed)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("导出失败行失败: %v", err), h.window)
+			return
+		}
+		h.appendLog(fmt.Sprintf("Exported %d failed rows to %s", written, path))
+	}, h.window)
+	base := strings.TrimSuffix(filepath.Base(run.csvPath), filepath.Ext(run.csvPath))
+	// Excel 数据导出为CSV
+	ext := "csv"
+	if run.config.SourceFormat() == engine.InputFormatJSONL {
+		ext = "jsonl"
+	}
+	saveDialog.SetFileName(base + "-failed." + ext)
+	saveDialog.Resize(fyne.NewSize(800, 600))
+	saveDialog.Show()
+}
+
+// 查看上次对比执行中响应不一致的行：左侧为行列表，右侧为差异字段和各目标的响应
+func (h *HTTPTool) showDiffs() {
+	run := h.lastRun
+	if run == nil || len(run.mismatches) == 0 {
+		return
+	}
+	
+	errorStyle := widget.RichTextStyle{ColorName: theme.ColorNameError, TextStyle: fyne.TextStyle{Bold: true}}
+	detail := widget.NewRichText()
+	detail.Wrapping = fyne.TextWrapBreak
+	showRow := func(result *engine.Result) {
+		segments := []widget.RichTextSegment{
+			&widget.TextSegment{Text: fmt.Sprintf("行 %d  参数 %s", result.RowIndex, result.Params), Style: widget.RichTextStyleSubHeading},
+		}
+		for _, diff := range result.Compare.Diffs {
+			segments = append(segments,
+				&widget.TextSegment{Text: diff.Path, Style: errorStyle},
+				&widget.TextSegment{
+					Text:  fmt.Sprintf("  %s: %s\n  %s: %s", result.Target, diff.Base, diff.Target, diff.Value),
+					Style: widget.RichTextStyleCodeBlock,
+				})
+		}
+		for i, resp := range result.Compare.Responses {
+			title := fmt.Sprintf("%s  状态 %d  %.1fms", resp.Target, resp.Status, resp.LatencyMs)
+			if i == 0 {
+				title += "  (基准)"
+			}
+			body := resp.Response
+			if resp.Status == 0 {
+				body = resp.Error
+			}
+			segments = append(segments,
+				&widget.TextSegment{Text: title, Style: widget.RichTextStyleStrong},
+				&widget.TextSegment{Text: body, Style: widget.RichTextStyleCodeBlock})
+		}
+		detail.Segments = segments
+		detail.Refresh()
+	}
+	
+	list := widget.NewList(
+		func() int { return len(run.mismatches) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			result := run.mismatches[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("行 %d (%d 处不同)", result.RowIndex, len(result.Compare.Diffs)))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		showRow(run.mismatches[id])
+	}
+	
+	summary := fmt.Sprintf("共 %d 行响应不一致", run.mismatchTotal)
+	if run.mismatchTotal > len(run.mismatches) {
+		summary += fmt.Sprintf("，仅显示前 %d 行，完整结果请使用 jsonl 结果文件", len(run.mismatches))
+	}
+	exportBtn := widget.NewButton("📤 导出差异", func() {
+		h.exportDiffs(run)
+	})
+	split := container.NewHSplit(list, container.NewScroll(detail))
+	split.SetOffset(0.25)
+	content := container.NewBorder(
+		container.NewBorder(nil, nil, nil, exportBtn, widget.NewLabel(summary)),
+		nil, nil, nil,
+		split,
+	)
+	diffDialog := dialog.NewCustom("🔀 响应差异", "关闭", content, h.window)
+	diffDialog.Resize(fyne.NewSize(1000, 700))
+	diffDialog.Show()
+	list.Select(0)
+}
+
+// 导出不一致的行，.jsonl 含各目标的完整响应，其余为CSV（每个差异字段一行）
+func (h *HTTPTool) exportDiffs(run *runRecord) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		path := writer.URI().Path()
+		writer.Close()
+		if err := engine.WriteMismatches(path, run.mismatches); err != nil {
+			dialog.ShowError(fmt.Errorf("导出差异失败: %v", err), h.window)
+			return
+		}
+		h.appendLog(fmt.Sprintf("Exported diff of %d rows to %s", len(run.mismatches), path))
+	}, h.window)
+	base := strings.TrimSuffix(filepath.Base(run.csvPath), filepath.Ext(run.csvPath))
+	saveDialog.SetFileName(base + "-diff.csv")
+	saveDialog.Resize(fyne.NewSize(800, 600))
+	saveDialog.Show()
+}
 
+// 开始执行，rows 不为空时只执行这些行号
+func (h *HTTPTool) launchExecution(config *engine.Config, csvPath string, resume bool, rows []int) {
 	h.mutex.Lock()
 	h.isRunning = true
 	h.mutex.Unlock()
 
 	h.startBtn.Disable()
+	h.resumeBtn.Disable()
+	h.retryBtn.Disable()
+	h.exportFailedBtn.Disable()
+	h.diffBtn.Disable()
 	h.stopBtn.Enable()
 	h.outputText.SetText("")
+	h.resetCharts()
 	
 	// 显示进度条和更新状态
 	h.progressBar.Show()
@@ -442,7 +875,135 @@ func (h *HTTPTool) startExecution() {
 	ctx, cancel := context.WithCancel(context.Background())
 	h.cancelFunc = cancel
 
-	go h.executeRequests(ctx)
+	checkpointPath := engine.CheckpointPath(filepath.Join(h.getConfigDir(), "checkpoints"), csvPath)
+	go h.executeRequests(ctx, config, csvPath, checkpointPath, resume, rows)
+}
+
+// 导出最近一次执行的统计报告，.json 为JSON格式，其余为文本
+func (h *HTTPTool) exportReport() {
+	if h.lastReport == nil {
+		return
+	}
+	report := *h.lastReport
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		path := writer.URI().Path()
+		writer.Close()
+		if err := engine.WriteReport(path, report); err != nil {
+			dialog.ShowError(err, h.window)
+			return
+		}
+		h.appendLog(fmt.Sprintf("Report exported to %s", path))
+	}, h.window)
+	saveDialog.SetFileName("report.json")
+	saveDialog.Resize(fyne.NewSize(800, 600))
+	saveDialog.Show()
+}
+
+// 预览指定数据行将要发送的请求，不进行任何网络请求，参数映射和模板错误标红显示
+func (h *HTTPTool) previewRequests() {
+	if h.needsVault(h.previewRequests) {
+		return
+	}
+	if err := h.validateInputs(); err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+	from, to, err := engine.ParseRowRange(h.previewRowsEntry.Text)
+	if err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+	config := h.collectConfig()
+	src, err := engine.OpenSource(h.csvPathEntry.Text, config)
+	if err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+	defer src.Close()
+	previews, err := engine.Preview(config, src, from, to)
+	if err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+
+	errorStyle := widget.RichTextStyle{ColorName: theme.ColorNameError, TextStyle: fyne.TextStyle{Bold: true}}
+	problems := 0
+	var segments []widget.RichTextSegment
+	for _, p := range previews {
+		heading := &widget.TextSegment{
+			Text:  fmt.Sprintf("行 %d → %s", p.RowIndex, p.Target),
+			Style: widget.RichTextStyleSubHeading,
+		}
+		if p.HasProblem() {
+			problems++
+			heading.Text += "  ⚠"
+			heading.Style.ColorName = theme.ColorNameError
+		}
+		segments = append(segments, heading)
+		for _, mappingErr := range p.MappingErrors {
+			segments = append(segments, &widget.TextSegment{
+				Text:  "参数映射错误 " + mappingErr + "（执行时该参数会被跳过）",
+				Style: errorStyle,
+			})
+		}
+		if p.Error != "" {
+			segments = append(segments, &widget.TextSegment{
+				Text:  p.Error + "（执行时该行会失败）",
+				Style: errorStyle,
+			})
+			continue
+		}
+		segments = append(segments, &widget.TextSegment{
+			Text:  strings.TrimRight(p.Text(), "\n"),
+			Style: widget.RichTextStyleCodeBlock,
+		})
+	}
+	summary := fmt.Sprintf("共 %d 行，%d 行有错误（仅预览，未发送任何请求）", len(previews), problems)
+	if len(previews) == 0 {
+		summary = "指定范围内没有数据行"
+	}
+	summaryText := &widget.TextSegment{Text: summary, Style: widget.RichTextStyleStrong}
+	if problems > 0 {
+		summaryText.Style = errorStyle
+	}
+	segments = append([]widget.RichTextSegment{summaryText}, segments...)
+
+	content := widget.NewRichText(segments...)
+	content.Wrapping = fyne.TextWrapBreak
+	previewDialog := dialog.NewCustom("🔍 请求预览", "关闭", container.NewScroll(content), h.window)
+	previewDialog.Resize(fyne.NewSize(900, 700))
+	previewDialog.Show()
+}
+
+// 把界面上的QPS和并发数应用到执行中的任务，无需停止重来
+func (h *HTTPTool) applyRunningLimits() {
+	qps, err := strconv.Atoi(strings.TrimSpace(h.qpsEntry.Text))
+	if err != nil || qps <= 0 {
+		dialog.ShowError(fmt.Errorf("QPS必须是大于0的整数"), h.window)
+		return
+	}
+	workers, err := strconv.Atoi(strings.TrimSpace(h.workersEntry.Text))
+	if err != nil || workers <= 0 {
+		dialog.ShowError(fmt.Errorf("并发数必须是大于0的整数"), h.window)
+		return
+	}
+
+	h.mutex.RLock()
+	runner := h.runner
+	h.mutex.RUnlock()
+	if runner == nil {
+		return
+	}
+	if err := runner.SetQPS(qps); err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+	if err := runner.SetWorkers(workers); err != nil {
+		dialog.ShowError(err, h.window)
+	}
 }
 
 func (h *HTTPTool) stopExecution() {
@@ -457,12 +1018,13 @@ func (h *HTTPTool) stopExecution() {
 	// 在UI线程中更新界面
 	fyne.Do(func() {
 		h.startBtn.Enable()
+		h.resumeBtn.Enable()
 		h.stopBtn.Disable()
 		h.statusLabel.SetText("执行已停止")
 		h.progressBar.Hide()
 	})
 	
-	h.appendLog("⏹ 用户手动停止执行")
+	h.appendLog("⏹ 用户手动停止执行，可点击\"继续执行\"从断点恢复")
 }
 
 func (h *HTTPTool) validateInputs() error {
@@ -470,7 +1032,7 @@ func (h *HTTPTool) validateInputs() error {
 		return fmt.Errorf("请求URL不能为空")
 	}
 	if strings.TrimSpace(h.csvPathEntry.Text) == "" {
-		return fmt.Errorf("请选择CSV文件")
+		return fmt.Errorf("请选择数据文件")
 	}
 	if _, err := strconv.Atoi(h.qpsEntry.Text); err != nil {
 		return fmt.Errorf("QPS必须是数字")
@@ -481,516 +1043,174 @@ func (h *HTTPTool) validateInputs() error {
 	if _, err := strconv.Atoi(h.retriesEntry.Text); err != nil {
 		return fmt.Errorf("Retries must be a number")
 	}
-	return nil
+	if _, err := strconv.Atoi(h.breakerEntry.Text); err != nil {
+		return fmt.Errorf("熔断阈值必须是数字")
+	}
+	if _, err := strconv.Atoi(h.cooldownEntry.Text); err != nil {
+		return fmt.Errorf("熔断冷却时间必须是数字")
+	}
+	if _, err := engine.ParseStages(h.profileEntry.Text); err != nil {
+		return err
+	}
+	if _, err := engine.ParseVars(h.envVarsEntry.Text); err != nil {
+		return err
+	}
+	if text := strings.TrimSpace(h.headerRowEntry.Text); text != "" {
+		if _, err := strconv.Atoi(text); err != nil {
+			return fmt.Errorf("标题行必须是数字")
+		}
+	}
+	config := h.collectConfig()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	
+	// 按标题行检查映射中引用的列名
+	header, err := engine.ReadHeader(h.csvPathEntry.Text, config)
+	if err != nil {
+		return fmt.Errorf("读取数据文件标题行失败: %v", err)
+	}
+	return engine.ValidateInput(config, header)
 }
 
-func (h *HTTPTool) executeRequests(ctx context.Context) {
+func (h *HTTPTool) executeRequests(ctx context.Context, config *engine.Config, csvPath, checkpointPath string, resume bool, rows []int) {
 	defer func() {
 		h.mutex.Lock()
 		h.isRunning = false
+		h.runner = nil
 		h.mutex.Unlock()
 		
 		// 在UI线程中更新按钮状态
 		fyne.Do(func() {
 			h.startBtn.Enable()
+			h.resumeBtn.Enable()
 			h.stopBtn.Disable()
+			h.applyBtn.Disable()
 		})
 		
 		// 确保最后的日志都被刷新
 		h.flushLogBuffer()
 	}()
 
-	// 解析配置
-	qps, _ := strconv.Atoi(h.qpsEntry.Text)
-	workers, _ := strconv.Atoi(h.workersEntry.Text)
-	maxRetries, _ := strconv.Atoi(h.retriesEntry.Text)
-	
-	ipList := strings.Split(strings.TrimSpace(h.ipListEntry.Text), "\n")
-	for i := range ipList {
-		ipList[i] = strings.TrimSpace(ipList[i])
+	src, err := engine.OpenSource(csvPath, config)
+	if err != nil {
+		h.appendLog(err.Error())
+		return
 	}
+	defer src.Close()
 
-	h.appendLog(fmt.Sprintf("Starting execution - QPS: %d, Workers: %d, Retries: %d", qps, workers, maxRetries))
-
-	// 打开CSV文件
-	file, err := os.Open(h.csvPathEntry.Text)
+	checkpoint, err := engine.OpenCheckpoint(checkpointPath, csvPath, resume)
 	if err != nil {
-		h.appendLog(fmt.Sprintf("Failed to open CSV file: %v", err))
+		h.appendLog(err.Error())
 		return
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-
-	// 创建请求队列和限流器 - 优化队列大小
-	requestQueue := make(chan RequestTask, workers*2) // 根据worker数量调整队列大小
-	rateLimiter := time.NewTicker(time.Second / time.Duration(qps))
-	defer rateLimiter.Stop()
-
-	// 创建错误通道用于收集错误信息
-	errorChan := make(chan error, workers)
-	var wg sync.WaitGroup
-
-	// Start worker goroutines - 优化worker管理，增强停止响应
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			defer func() {
-				if r := recover(); r != nil {
-					select {
-					case errorChan <- fmt.Errorf("worker %d panic: %v", workerID, r):
-					case <-ctx.Done():
-					}
-				}
-			}()
-			
-			for {
-				select {
-				case <-ctx.Done():
-					return // 优先检查取消信号
-				case task, ok := <-requestQueue:
-					if !ok {
-						return
-					}
-					select {
-					case <-ctx.Done():
-						return // 在限流前再次检查
-					case <-rateLimiter.C:
-						h.sendRequest(ctx, task, ipList, maxRetries)
-					}
-				}
-			}
-		}(i)
-	}
+	defer checkpoint.Close()
 
-	// 先读取所有行以计算总数 - 优化内存使用
-	allRows := make([][]string, 0, 1000) // 预分配容量
-	for {
-		row, err := reader.Read()
-		if err != nil {
-			break
-		}
-		allRows = append(allRows, row)
+	runner := engine.NewRunner(config, h.handleEngineEvent)
+	runner.SetCheckpoint(checkpoint)
+	if rows != nil {
+		runner.SetRows(rows)
 	}
-	
-	totalRows := len(allRows) - 1 // 减去标题行
-	if totalRows <= 0 {
-		h.appendLog("CSV文件没有数据行")
-		close(requestQueue)
-		wg.Wait()
+	h.mutex.Lock()
+	h.runner = runner
+	h.mutex.Unlock()
+	fyne.Do(h.applyBtn.Enable)
+	summary, err := runner.Run(ctx, src)
+	if err != nil {
+		h.appendLog(err.Error())
+		return
+	}
+	if summary.Total == 0 {
 		return
 	}
 	
-	h.appendLog(fmt.Sprintf("Found %d data rows to process", totalRows))
-	
-	// 处理CSV数据 - 优化处理逻辑
-	rowIndex := 0
-	successCount := 0
-	errorCount := 0
-	processedCount := 0
-	batchSize := 100 // 增加批量处理大小，减少UI更新频率
-	
-	// 启动错误监控goroutine
-	go func() {
-		for err := range errorChan {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				h.appendLog(fmt.Sprintf("Worker error: %v", err))
-			}
-		}
-	}()
-
-	// 使用更高效的循环，定期检查停止信号
-	checkInterval := 10 // 每10行检查一次停止信号
-	for i, row := range allRows {
-		// 定期检查停止信号，避免处理过多数据
-		if i%checkInterval == 0 {
-			select {
-			case <-ctx.Done():
-				h.appendLog("Execution cancelled during processing")
-				close(requestQueue)
-				wg.Wait()
-				close(errorChan)
-				return
-			default:
-			}
-		}
-
-		rowIndex++
-		if rowIndex <= 1 { // Skip header row
-			continue
+	// 最终状态更新，直接设置避免被进度节流丢弃
+	done := summary.Done()
+	status, logStatus := "执行完成", "completed"
+	if summary.Stopped {
+		status, logStatus = "执行已停止", "stopped"
+	}
+	status = fmt.Sprintf("%s - %s", status, formatCounts(summary.Counts))
+	if notRun := summary.Total - done; notRun > 0 {
+		status += fmt.Sprintf(", 未执行: %s%d", approxLabel(summary.Estimated), notRun)
+	}
+	report := summary.Report
+	run := &runRecord{
+		config:        config,
+		csvPath:       csvPath,
+		failed:        summary.Outcomes.Unsuccessful(),
+		mismatches:    summary.Mismatches,
+		mismatchTotal: summary.Mismatch,
+	}
+	fyne.Do(func() {
+		h.progressBar.SetValue(float64(done) / float64(summary.Total))
+		h.statusLabel.SetText(status)
+		h.statsLabel.SetText(formatStats(report))
+		h.lastReport = &report
+		h.reportBtn.Enable()
+		h.lastRun = run
+		if len(run.failed) > 0 {
+			h.retryBtn.Enable()
+			h.exportFailedBtn.Enable()
 		}
-
-		paramsJSON, err := h.genParams(row)
-		if err != nil {
-			h.appendLog(fmt.Sprintf("Row %d param generation failed: %v", rowIndex, err))
-			errorCount++
-			processedCount++
-			// 错误时也检查停止信号
-			select {
-			case <-ctx.Done():
-				h.appendLog("Execution cancelled during error handling")
-				close(requestQueue)
-				wg.Wait()
-				close(errorChan)
-				return
-			default:
-				if processedCount%batchSize == 0 || processedCount == totalRows {
-					h.updateProgress(processedCount, totalRows, successCount, errorCount)
-				}
-			}
-			continue
+		if len(run.mismatches) > 0 {
+			h.diffBtn.Enable()
 		}
+	})
+	h.appendLog(fmt.Sprintf("Execution %s - Total: %d, %s, Not run: %d",
+		logStatus, summary.Total, summary.Counts, summary.Total-done))
+	h.appendLog(report.Text())
+}
 
-		// 批量发送任务，减少channel操作开销
-		select {
-		case <-ctx.Done():
-			h.appendLog("Execution cancelled before sending task")
-			close(requestQueue)
-			wg.Wait()
-			close(errorChan)
-			return
-		case requestQueue <- RequestTask{
-			ParamsJSON: paramsJSON,
-			RowIndex:   rowIndex,
-		}:
-			successCount++
-			processedCount++
-		}
-		
-		// 批量更新进度，减少UI更新频率
-		if processedCount%batchSize == 0 || processedCount == totalRows {
-			h.updateProgress(processedCount, totalRows, successCount, errorCount)
-		}
+// 将执行引擎的事件转换为日志和进度更新
+func (h *HTTPTool) handleEngineEvent(event engine.Event) {
+	switch event.Type {
+	case engine.EventProgress:
+		p := event.Progress
+		h.updateProgress(p)
+	case engine.EventSample:
+		s := event.Sample
+		fyne.Do(func() {
+			h.pushSample(s)
+		})
+	case engine.EventLog, engine.EventRowSucceeded, engine.EventRowFailed:
+		h.appendLog(event.Message)
 	}
+}
 
-	close(requestQueue)
-	wg.Wait()
-	close(errorChan)
-	
-	// 最终状态更新
-	h.updateProgress(totalRows, totalRows, successCount, errorCount)
-	
-	fyne.Do(func() {
-		h.progressBar.SetValue(1.0)
-		h.statusLabel.SetText(fmt.Sprintf("执行完成 - 成功: %d, 错误: %d", successCount, errorCount))
-	})
-	h.appendLog(fmt.Sprintf("Execution completed - Success: %d, Error: %d", successCount, errorCount))
+// 创建实时图表
+func (h *HTTPTool) setupCharts() {
+	h.qpsChart = newLineChart("吞吐 (请求/秒)",
+		[]string{"实际", "目标"},
+		[]color.Color{colorBlue, colorGray})
+	h.latencyChart = newLineChart("延迟 (ms)",
+		[]string{"p50", "p90", "p99"},
+		[]color.Color{colorGreen, colorOrange, colorRed})
+	h.errorChart = newLineChart("失败 (行/秒)",
+		[]string{"4xx", "5xx", "断言失败", "重试用尽", "不一致", "其他"},
+		[]color.Color{colorOrange, colorRed, colorPurple, colorBlue, colorGreen, colorGray})
 }
 
-// 新的参数生成函数，支持配置化映射
-func (h *HTTPTool) genParams(rows []string) ([]byte, error) {
-	if len(rows) == 1 {
-		rows = strings.Split(rows[0], "\t")
-	}
-	
-	// 获取参数映射配置
-	mappings := h.getParamMappings()
-	if len(mappings) == 0 {
-		// 如果没有配置映射，使用原来的逻辑作为兼容
-		return h.genParamsLegacy(rows)
-	}
-	
-	// 根据参数模式生成不同格式的参数
-	if h.config.ParamMode == "array" {
-		return h.genParamsArray(rows, mappings)
-	} else {
-		return h.genParamsObject(rows, mappings)
-	}
+// 开始新的执行时清空图表
+func (h *HTTPTool) resetCharts() {
+	h.qpsChart.reset()
+	h.latencyChart.reset()
+	h.errorChart.reset()
 }
 
-// 生成对象格式参数
-func (h *HTTPTool) genParamsObject(rows []string, mappings []ParamMapping) ([]byte, error) {
-	params := make(map[string]interface{})
-	
-	for _, mapping := range mappings {
-		value, err := h.extractValueFromCSV(rows, mapping)
-		if err != nil {
-			h.appendLog(fmt.Sprintf("参数映射错误 [%s]: %v", mapping.ParamName, err))
-			continue
-		}
-		params[mapping.ParamName] = value
-	}
-	
-	return json.Marshal(params)
-}
-
-// 生成数组格式参数
-func (h *HTTPTool) genParamsArray(rows []string, mappings []ParamMapping) ([]byte, error) {
-	// 按数组索引排序映射
-	sort.Slice(mappings, func(i, j int) bool {
-		return mappings[i].ArrayIndex < mappings[j].ArrayIndex
-	})
-	
-	// 创建紧凑的数组，按顺序填充参数
-	var params []interface{}
-	
-	// 填充数组参数
-	for _, mapping := range mappings {
-		value, err := h.extractValueFromCSV(rows, mapping)
-		if err != nil {
-			h.appendLog(fmt.Sprintf("参数映射错误 [索引%d]: %v", mapping.ArrayIndex, err))
-			continue
-		}
-		params = append(params, value)
-	}
-	
-	return json.Marshal(params)
-}
-
-// 兼容原有逻辑的函数
-func (h *HTTPTool) genParamsLegacy(rows []string) ([]byte, error) {
-	if len(rows) < 5 {
-		return nil, fmt.Errorf("CSV行数据不足")
-	}
-	
-	req := []interface{}{
-		rows[4], // settleOrderId
-		0,       // orderId - 根据需要调整
-	}
-	
-	if len(rows) > 2 {
-		if orderId, err := strconv.Atoi(rows[2]); err == nil {
-			req[1] = orderId
-		}
-	}
-
-	return json.Marshal(req)
-}
-
-// 从CSV行中提取值并转换类型
-func (h *HTTPTool) extractValueFromCSV(rows []string, mapping ParamMapping) (interface{}, error) {
-	var rawValue string
-	
-	// 尝试按索引获取值
-	if index, err := strconv.Atoi(mapping.CSVColumn); err == nil {
-		if index >= 0 && index < len(rows) {
-			rawValue = rows[index]
-		} else {
-			rawValue = mapping.DefaultValue
-		}
-	} else {
-		// 按列名获取值（需要CSV头部支持）
-		rawValue = mapping.DefaultValue // 暂时使用默认值，后续可扩展支持列名
-	}
-	
-	// 如果值为空，使用默认值
-	if strings.TrimSpace(rawValue) == "" {
-		rawValue = mapping.DefaultValue
-	}
-	
-	// 根据类型转换值
-	return h.convertValueByType(rawValue, mapping.ParamType)
-}
-
-// 根据类型转换值
-func (h *HTTPTool) convertValueByType(value, paramType string) (interface{}, error) {
-	value = strings.TrimSpace(value)
-	
-	switch paramType {
-	case "string":
-		return value, nil
-	case "int":
-		if value == "" {
-			return 0, nil
-		}
-		return strconv.Atoi(value)
-	case "float":
-		if value == "" {
-			return 0.0, nil
-		}
-		return strconv.ParseFloat(value, 64)
-	case "bool":
-		if value == "" {
-			return false, nil
-		}
-		return strconv.ParseBool(value)
-	case "string[]":
-		if value == "" {
-			return []string{}, nil
-		}
-		// 支持多种分隔符：逗号、分号、竖线、空格
-		separators := []string{",", ";", "|", " "}
-		var parts []string
-		for _, sep := range separators {
-			if strings.Contains(value, sep) {
-				parts = strings.Split(value, sep)
-				break
-			}
-		}
-		if len(parts) == 0 {
-			parts = []string{value}
-		}
-		// 清理空白字符
-		var result []string
-		for _, part := range parts {
-			trimmed := strings.TrimSpace(part)
-			if trimmed != "" {
-				result = append(result, trimmed)
-			}
-		}
-		return result, nil
-	case "int[]":
-		if value == "" {
-			return []int{}, nil
-		}
-		// 支持多种分隔符：逗号、分号、竖线、空格
-		separators := []string{",", ";", "|", " "}
-		var parts []string
-		for _, sep := range separators {
-			if strings.Contains(value, sep) {
-				parts = strings.Split(value, sep)
-				break
-			}
-		}
-		if len(parts) == 0 {
-			parts = []string{value}
-		}
-		// 转换为整数
-		var result []int
-		for _, part := range parts {
-			trimmed := strings.TrimSpace(part)
-			if trimmed != "" {
-				if num, err := strconv.Atoi(trimmed); err == nil {
-					result = append(result, num)
-				}
-			}
-		}
-		return result, nil
-	default:
-		return value, nil
-	}
-}
-
-func (h *HTTPTool) sendRequest(ctx context.Context, task RequestTask, ipList []string, maxRetries int) {
-	retryCount := 0
-	startTime := time.Now()
-	
-	// 预编译body模板，避免重复解析
-	var bodyTemplate map[string]interface{}
-	if err := json.Unmarshal([]byte(h.bodyEntry.Text), &bodyTemplate); err != nil {
-		h.appendLog(fmt.Sprintf("Row %d body template parse failed: %v", task.RowIndex, err))
-		return
-	}
-	
-	for retryCount < maxRetries {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		} 
-		// 为每次重试添加指数退避延迟，避免惊群效应
-		if retryCount > 0 {
-			backoffDelay := time.Duration(retryCount*retryCount*100) * time.Millisecond
-			if backoffDelay > 5*time.Second {
-				backoffDelay = 5 * time.Second
-			}
-			time.Sleep(backoffDelay)
-		}
-
-		// 选择IP
-		randomIP := ipList[task.RowIndex%len(ipList)]
-
-		// 构造请求体 - 使用模板副本避免并发问题
-		data := make(map[string]interface{})
-		for k, v := range bodyTemplate {
-			data[k] = v
-		}
-		data["ipPort"] = randomIP
-		data["jsonParam"] = string(task.ParamsJSON)
-
-		body, err := json.Marshal(data)
-		if err != nil {
-			h.appendLog(fmt.Sprintf("Row %d JSON marshal failed: %v", task.RowIndex, err))
-			return
-		}
-
-		// 创建带超时的子上下文
-		reqCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
-		
-		// 创建请求 - 使用bytes.NewBuffer避免重复分配
-		req, err := http.NewRequestWithContext(reqCtx, "POST", h.urlEntry.Text, bytes.NewBuffer(body))
-		if err != nil {
-			cancel()
-			h.appendLog(fmt.Sprintf("Row %d request creation failed: %v", task.RowIndex, err))
-			return
-		}
-
-		// 设置请求头
-		h.setHeaders(req)
-
-		// 发送请求 - 使用优化的HTTP客户端
-		requestStart := time.Now()
-		resp, err := httpClient.Do(req)
-		requestDuration := time.Since(requestStart)
-		cancel() // 立即取消上下文，释放资源
-		
-		if err != nil {
-			retryCount++
-			h.appendLog(fmt.Sprintf("Row %d request failed (retry %d/%d, duration: %v): %v",
-				task.RowIndex, retryCount, maxRetries, requestDuration, err))
-			continue
-		}
-
-		// 优化响应读取 - 限制响应大小避免内存问题
-		const maxResponseSize = 5 * 1024 * 1024 // 5MB限制，减少内存使用
-		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
-		resp.Body.Close()
-		
-		if err != nil {
-			retryCount++
-			h.appendLog(fmt.Sprintf("Row %d response read failed (retry %d/%d): %v", task.RowIndex, retryCount, maxRetries, err))
-			continue
-		}
-
-		// 检查响应状态码
-		if resp.StatusCode >= 500 {
-			retryCount++
-			h.appendLog(fmt.Sprintf("Row %d server error %d (retry %d/%d)", task.RowIndex, resp.StatusCode, retryCount, maxRetries))
-			continue
-		}
-
-		if resp.StatusCode >= 400 {
-			// 4xx错误不重试，直接记录为失败
-			h.appendLog(fmt.Sprintf("Row %d client error %d: %s", task.RowIndex, resp.StatusCode, string(respBody)))
-			return
-		}
-
-		if strings.Contains(string(respBody), "call failed") {
-			retryCount++
-			h.appendLog(fmt.Sprintf("第%d行call failed(重试%d/%d): %s", task.RowIndex, retryCount, maxRetries, string(respBody)))
-			continue
-		}
-
-		// 记录成功响应和耗时
-		totalDuration := time.Since(startTime)
-		h.appendLog(fmt.Sprintf("Row %d success in %v (request: %v): %s",
-			task.RowIndex, totalDuration, requestDuration, string(respBody)))
-		return
-	}
-	
-	h.appendLog(fmt.Sprintf("Row %d final failure after %d retries, total time: %v", task.RowIndex, maxRetries, time.Since(startTime)))
-}
-
-func (h *HTTPTool) setHeaders(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json;charset=UTF-8")
-	req.Header.Set("Accept", "application/json, text/plain, */*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Host", "intest-manager.jd.com")
-	req.Header.Set("Origin", "http://xingyun.jd.com")
-	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Referer", "http://xingyun.jd.com/deeptest/quicktest/list?env=master&parentId=21254&Id=137790")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36")
-	
-	if cookie := strings.TrimSpace(h.cookieEntry.Text); cookie != "" {
-		req.Header.Set("Cookie", cookie)
-	}
+// 把一次实时采样追加到图表
+func (h *HTTPTool) pushSample(s engine.Sample) {
+	h.qpsChart.push(s.QPS, s.TargetQPS)
+	h.latencyChart.push(s.Latency.P50, s.Latency.P90, s.Latency.P99)
+	rows := s.Rows
+	h.errorChart.push(
+		float64(rows.ClientError),
+		float64(rows.ServerError),
+		float64(rows.AssertFailed),
+		float64(rows.Exhausted),
+		float64(rows.Mismatch),
+		float64(rows.ParamErrors+rows.Cancelled),
+	)
 }
 
 // 设置优化的日志缓冲系统
@@ -1041,8 +1261,9 @@ func (h *HTTPTool) setupProgressUpdater() {
 					progress := float64(u.processed) / float64(u.total)
 					h.progressBar.SetValue(progress)
 					// 简化状态文本，减少UI计算
-					h.statusLabel.SetText(fmt.Sprintf("%d/%d (%.0f%%) 成功:%d 错误:%d",
-						u.processed, u.total, progress*100, u.success, u.errors))
+					h.statusLabel.SetText(fmt.Sprintf("%d/%s%d (%.0f%%) 目标QPS: %.1f %s",
+						u.processed, approxLabel(u.estimated), u.total, progress*100, u.targetQPS, formatCounts(u.counts)))
+					h.statsLabel.SetText(formatStats(u.stats))
 				})
 			}(update)
 		}
@@ -1050,13 +1271,15 @@ func (h *HTTPTool) setupProgressUpdater() {
 }
 
 // 优化的进度更新函数
-func (h *HTTPTool) updateProgress(processed, total, success, errors int) {
+func (h *HTTPTool) updateProgress(p engine.Progress) {
 	select {
 	case h.progressChannel <- progressUpdate{
-		processed: processed,
-		total:     total,
-		success:   success,
-		errors:    errors,
+		processed: p.Processed,
+		total:     p.Total,
+		estimated: p.Estimated,
+		targetQPS: p.TargetQPS,
+		stats:     p.Stats,
+		counts:    p.Counts,
 	}:
 		// 成功发送进度更新
 	default:
@@ -1064,6 +1287,54 @@ func (h *HTTPTool) updateProgress(processed, total, success, errors int) {
 	}
 }
 
+// 总行数为估算值时加上"约"
+func approxLabel(estimated bool) string {
+	if estimated {
+		return "约"
+	}
+	return ""
+}
+
+// 状态栏中的延迟、吞吐和错误率
+func formatStats(r engine.Report) string {
+	if r.Requests == 0 {
+		return ""
+	}
+	return fmt.Sprintf("延迟 p50 %.1fms p90 %.1fms p99 %.1fms max %.1fms | 吞吐 %.1f/s | 错误率 %.2f%%",
+		r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max, r.Throughput, r.ErrorRate*100)
+}
+
+// 状态栏中的结果统计，失败只列出非零的分类
+func formatCounts(c engine.Counts) string {
+	var details []string
+	for _, item := range []struct {
+		name  string
+		count int
+	}{
+		{"4xx", c.ClientError},
+		{"5xx", c.ServerError},
+		{"断言", c.AssertFailed},
+		{"重试耗尽", c.Exhausted},
+		{"参数", c.ParamErrors},
+		{"不一致", c.Mismatch},
+	} {
+		if item.count > 0 {
+			details = append(details, fmt.Sprintf("%s %d", item.name, item.count))
+		}
+	}
+	text := fmt.Sprintf("成功: %d, 失败: %d", c.Success, c.Failed())
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, ", ") + ")"
+	}
+	if c.Cancelled > 0 {
+		text += fmt.Sprintf(", 取消: %d", c.Cancelled)
+	}
+	if c.Skipped > 0 {
+		text += fmt.Sprintf(", 跳过: %d", c.Skipped)
+	}
+	return text
+}
+
 // 优化的日志刷新函数 - 严格减少UI阻塞
 func (h *HTTPTool) flushLogBuffer() {
 	// 防止过于频繁的UI更新 - 增加到500ms
@@ -1166,19 +1437,123 @@ func (h *HTTPTool) stopLogBuffer() {
 	}
 }
 
+// 从界面组件收集当前配置
+func (h *HTTPTool) collectConfig() *engine.Config {
+	h.saveEnvironmentVars()
+	environment := h.envSelect.Selected
+	if environment == envNone {
+		environment = ""
+	}
+	var secrets map[string]string
+	if h.vault != nil {
+		secrets = h.vault.Secrets()
+	}
+	return &engine.Config{
+		Secrets:          secrets,
+		Environments:     append([]engine.Environment(nil), h.environments...),
+		Environment:      environment,
+		Method:           h.methodSelect.Selected,
+		URL:              h.urlEntry.Text,
+		Cookie:           h.cookieEntry.Text,
+		BodyTemp:         h.bodyEntry.Text,
+		BodyFormat:       h.bodyFormatSelect.Selected,
+		Headers:          h.getHeaders(),
+		Assertions:       h.getAssertions(),
+		AssertDefault:    h.assertDefaultSelect.Selected,
+		IPList:           strings.Split(h.ipListEntry.Text, "\n"),
+		BreakerThreshold: h.parseIntOrDefault(h.breakerEntry.Text, 5),
+		BreakerCooldown:  h.parseIntOrDefault(h.cooldownEntry.Text, 10),
+		Preflight:        h.preflightMethod(),
+		Balance:          h.balanceSelect.Selected,
+		BalanceKey:       strings.TrimSpace(h.balanceKeyEntry.Text),
+		PreflightPath:    strings.TrimSpace(h.preflightPathEntry.Text),
+		CompareMode:      h.compareCheck.Checked,
+		CompareIgnore:    splitList(h.compareIgnoreEntry.Text),
+		QPS:              h.parseIntOrDefault(h.qpsEntry.Text, 25),
+		Profile:          h.parseProfile(),
+		Workers:          h.parseIntOrDefault(h.workersEntry.Text, 100),
+		MaxRetries:       h.parseIntOrDefault(h.retriesEntry.Text, 3),
+		ParamMappings:    h.getParamMappings(),
+		InputFormat:      h.inputFormatSelect.Selected,
+		Encoding:         h.selectedEncoding(),
+		Sheet:            strings.TrimSpace(h.sheetEntry.Text),
+		HeaderRow:        h.parseIntOrDefault(h.headerRowEntry.Text, 0),
+		ParamMode:        h.paramModeSelect.Selected,
+		ResultFile:       strings.TrimSpace(h.resultPathEntry.Text),
+		ResultFormat:     h.resultFormatSelect.Selected,
+	}
+}
+
+// 保存配置，发现明文凭据时默认移入保险库，配置中改为 ${secret:名称} 引用。
+// 凭据按字段名识别（见 engine.FindCredentials），关闭对话框不保存；
+// 仍要明文保存时需要再次确认
 func (h *HTTPTool) saveConfig() {
-	config := &Config{
-		URL:           h.urlEntry.Text,
-		Cookie:        h.cookieEntry.Text,
-		BodyTemp:      h.bodyEntry.Text,
-		IPList:        strings.Split(h.ipListEntry.Text, "\n"),
-		QPS:           h.parseIntOrDefault(h.qpsEntry.Text, 25),
-		Workers:       h.parseIntOrDefault(h.workersEntry.Text, 100),
-		MaxRetries:    h.parseIntOrDefault(h.retriesEntry.Text, 3),
-		ParamMappings: h.getParamMappings(),
-		ParamMode:     h.paramModeSelect.Selected,
+	config := h.collectConfig()
+	creds := engine.FindCredentials(config, nil)
+	if len(creds) == 0 {
+		h.writeConfigFile(config)
+		return
+	}
+
+	fields := make([]string, len(creds))
+	for i, cred := range creds {
+		fields[i] = "• " + cred.Field
+	}
+	message := widget.NewLabel(fmt.Sprintf("配置中有 %d 处明文凭据，保存的文件被分享后可能泄露:\n%s\n\n"+
+		"移入本地加密保险库后，配置中只保存 ${secret:名称} 引用。\n"+
+		"凭据按名称识别：Cookie，以及名称含 cookie、token、password、secret 等词的请求头、参数和环境变量，其他字段中的凭据需要自行检查。",
+		len(creds), strings.Join(fields, "\n")))
+	message.Wrapping = fyne.TextWrapWord
+
+	confirm := dialog.NewCustomWithoutButtons("发现明文凭据", message, h.window)
+	migrateBtn := widget.NewButton("🔐 移入保险库", func() {
+		confirm.Hide()
+		h.unlockVault(func() {
+			if err := h.moveCredentialsToVault(config); err != nil {
+				dialog.ShowError(err, h.window)
+				return
+			}
+			h.writeConfigFile(config)
+		})
+	})
+	migrateBtn.Importance = widget.HighImportance
+	plainBtn := widget.NewButton("明文保存", func() {
+		confirm.Hide()
+		dialog.ShowConfirm("确认明文保存",
+			fmt.Sprintf("%d 处凭据将以明文写入配置文件，任何拿到该文件的人都能看到。确定仍要明文保存？", len(creds)),
+			func(ok bool) {
+				if ok {
+					h.writeConfigFile(config)
+				}
+			}, h.window)
+	})
+	plainBtn.Importance = widget.DangerImportance
+	cancelBtn := widget.NewButton("取消", confirm.Hide)
+	confirm.SetButtons([]fyne.CanvasObject{cancelBtn, plainBtn, migrateBtn})
+	confirm.Resize(fyne.NewSize(520, 0))
+	confirm.Show()
+}
+
+// 把配置中的明文凭据存入保险库并替换为引用，界面同步显示替换后的配置
+func (h *HTTPTool) moveCredentialsToVault(config *engine.Config) error {
+	creds := engine.FindCredentials(config, h.vault.Secrets())
+	for _, cred := range creds {
+		if err := h.vault.Set(cred.Name, cred.Value); err != nil {
+			return err
+		}
+	}
+	if err := h.vault.Save(); err != nil {
+		return err
 	}
+	engine.ReplaceCredentials(config, creds)
+	config.Secrets = h.vault.Secrets()
+	h.applyConfig(config)
+	h.appendLog(fmt.Sprintf("Moved %d credentials into the vault", len(creds)))
+	return nil
+}
 
+// 选择保存位置并写入配置文件
+func (h *HTTPTool) writeConfigFile(config *engine.Config) {
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("Config serialization failed: %v", err), h.window)
@@ -1224,7 +1599,7 @@ func (h *HTTPTool) loadConfigFromFile() {
 			return
 		}
 
-		var config Config
+		var config engine.Config
 		if err := json.Unmarshal(data, &config); err != nil {
 			dialog.ShowError(fmt.Errorf("Config file parse failed: %v", err), h.window)
 			return
@@ -1247,7 +1622,7 @@ func (h *HTTPTool) loadDefaultConfig() bool {
 		return false
 	}
 
-	var config Config
+	var config engine.Config
 	if err := json.Unmarshal(data, &config); err != nil {
 		return false
 	}
@@ -1256,14 +1631,60 @@ func (h *HTTPTool) loadDefaultConfig() bool {
 	return true
 }
 
-func (h *HTTPTool) applyConfig(config *Config) {
+func (h *HTTPTool) applyConfig(config *engine.Config) {
+	h.environments = append([]engine.Environment(nil), config.Environments...)
+	h.currentEnv = ""
+	h.refreshEnvironments(config.Environment)
+	h.methodSelect.SetSelected(config.RequestMethod())
 	h.urlEntry.SetText(config.URL)
 	h.cookieEntry.SetText(config.Cookie)
 	h.bodyEntry.SetText(config.BodyTemp)
+	h.bodyFormatSelect.SetSelected(config.RequestBodyFormat())
+	h.setHeaders(config.Headers)
+	h.setAssertions(config.RequestAssertions())
+	if config.AssertDefault != "" {
+		h.assertDefaultSelect.SetSelected(config.AssertDefault)
+	} else {
+		h.assertDefaultSelect.SetSelected(engine.OutcomeSuccess)
+	}
 	h.ipListEntry.SetText(strings.Join(config.IPList, "\n"))
+	h.breakerEntry.SetText(strconv.Itoa(config.BreakerThreshold))
+	if config.BreakerCooldown > 0 {
+		h.cooldownEntry.SetText(strconv.Itoa(config.BreakerCooldown))
+	} else {
+		h.cooldownEntry.SetText("10")
+	}
+	if config.Preflight == engine.PreflightNone {
+		h.preflightSelect.SetSelected(preflightOff)
+	} else {
+		h.preflightSelect.SetSelected(config.Preflight)
+	}
+	h.preflightPathEntry.SetText(config.PreflightPath)
+	if config.Balance != "" {
+		h.balanceSelect.SetSelected(config.Balance)
+	} else {
+		h.balanceSelect.SetSelected(engine.BalanceRoundRobin)
+	}
+	h.balanceKeyEntry.SetText(config.BalanceKey)
+	h.compareCheck.SetChecked(config.CompareMode)
+	h.compareIgnoreEntry.SetText(strings.Join(config.CompareIgnore, ", "))
 	h.qpsEntry.SetText(strconv.Itoa(config.QPS))
+	h.profileEntry.SetText(engine.FormatStages(config.Profile))
 	h.workersEntry.SetText(strconv.Itoa(config.Workers))
 	h.retriesEntry.SetText(strconv.Itoa(config.MaxRetries))
+	h.resultPathEntry.SetText(config.ResultFile)
+	if config.ResultFormat != "" {
+		h.resultFormatSelect.SetSelected(config.ResultFormat)
+	}
+	
+	h.encodingSelect.SetSelected(config.DataEncoding())
+	h.inputFormatSelect.SetSelected(config.SourceFormat())
+	h.sheetEntry.SetText(config.Sheet)
+	if config.HeaderRow > 0 {
+		h.headerRowEntry.SetText(strconv.Itoa(config.HeaderRow))
+	} else {
+		h.headerRowEntry.SetText("1")
+	}
 	
 	// 应用参数模式配置
 	if config.ParamMode != "" {
@@ -1282,6 +1703,374 @@ func (h *HTTPTool) getConfigDir() string {
 	return configDir
 }
 
+// 预检下拉框中表示不检查的选项
+const preflightOff = "不检查"
+
+// 当前选择的预检方式
+func (h *HTTPTool) preflightMethod() string {
+	if h.preflightSelect.Selected == preflightOff {
+		return engine.PreflightNone
+	}
+	return h.preflightSelect.Selected
+}
+
+// 立即检查IP列表中的每个目标，未选择预检方式时按TCP检查
+func (h *HTTPTool) checkTargets() {
+	method := h.preflightMethod()
+	if method == engine.PreflightNone {
+		method = engine.PreflightTCP
+	}
+	config := h.collectConfig()
+	ipList, err := config.IPs()
+	if err != nil {
+		dialog.ShowError(err, h.window)
+		return
+	}
+	scheme := config.TargetScheme()
+	path := strings.TrimSpace(h.preflightPathEntry.Text)
+	h.checkTargetsBtn.Disable()
+	go func() {
+		checks := engine.CheckTargets(context.Background(), ipList, method, scheme, path)
+		lines := make([]string, 0, len(checks))
+		failed := 0
+		for _, check := range checks {
+			if check.OK {
+				lines = append(lines, "✅ "+check.String())
+			} else {
+				failed++
+				lines = append(lines, "❌ "+check.String())
+			}
+			h.appendLog("Preflight " + check.String())
+		}
+		fyne.Do(func() {
+			h.checkTargetsBtn.Enable()
+			title := fmt.Sprintf("目标检查 (%s): %d 个可用, %d 个不可用", method, len(checks)-failed, failed)
+			dialog.ShowInformation(title, strings.Join(lines, "\n"), h.window)
+		})
+	}()
+}
+
+// 环境下拉框中表示不使用环境的选项
+const envNone = "不使用环境"
+
+// 切换环境：先保存正在编辑的变量，再显示新环境的变量
+func (h *HTTPTool) switchEnvironment(name string) {
+	h.saveEnvironmentVars()
+	h.currentEnv = ""
+	if name == envNone {
+		h.envVarsEntry.SetText("")
+		h.envVarsEntry.Disable()
+		h.envDeleteBtn.Disable()
+		return
+	}
+	for _, env := range h.environments {
+		if env.Name == name {
+			h.envVarsEntry.SetText(engine.FormatVars(env.Vars))
+		}
+	}
+	h.currentEnv = name
+	h.envVarsEntry.Enable()
+	h.envDeleteBtn.Enable()
+}
+
+// 把变量编辑框的内容保存到对应的环境，格式错误时保留原来的变量，开始执行前由 validateInputs 报告错误
+func (h *HTTPTool) saveEnvironmentVars() {
+	if h.currentEnv == "" {
+		return
+	}
+	vars, err := engine.ParseVars(h.envVarsEntry.Text)
+	if err != nil {
+		return
+	}
+	for i := range h.environments {
+		if h.environments[i].Name == h.currentEnv {
+			h.environments[i].Vars = vars
+		}
+	}
+}
+
+// 刷新环境下拉框并选中指定的环境，不存在时选择不使用环境
+func (h *HTTPTool) refreshEnvironments(selected string) {
+	options := []string{envNone}
+	found := false
+	for _, env := range h.environments {
+		options = append(options, env.Name)
+		found = found || env.Name == selected
+	}
+	if !found {
+		selected = envNone
+	}
+	h.envSelect.Options = options
+	h.envSelect.Selected = ""
+	h.envSelect.SetSelected(selected)
+}
+
+// 新建环境，变量从当前环境复制，便于只修改不同的部分
+func (h *HTTPTool) addEnvironment() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("如 test、pre、prod")
+	dialog.ShowForm("新建环境", "创建", "取消", []*widget.FormItem{
+		widget.NewFormItem("名称", nameEntry),
+	}, func(ok bool) {
+		name := strings.TrimSpace(nameEntry.Text)
+		if !ok || name == "" {
+			return
+		}
+		if name == envNone {
+			dialog.ShowError(fmt.Errorf("环境名称不能为 %s", envNone), h.window)
+			return
+		}
+		for _, env := range h.environments {
+			if env.Name == name {
+				dialog.ShowError(fmt.Errorf("环境名称重复: %s", name), h.window)
+				return
+			}
+		}
+		h.saveEnvironmentVars()
+		vars := make(map[string]string)
+		for _, env := range h.environments {
+			if env.Name == h.currentEnv {
+				for key, value := range env.Vars {
+					vars[key] = value
+				}
+			}
+		}
+		h.environments = append(h.environments, engine.Environment{Name: name, Vars: vars})
+		h.currentEnv = ""
+		h.refreshEnvironments(name)
+	}, h.window)
+}
+
+// 删除当前环境
+func (h *HTTPTool) deleteEnvironment() {
+	name := h.currentEnv
+	if name == "" {
+		return
+	}
+	dialog.ShowConfirm("删除环境", fmt.Sprintf("确定删除环境 %s 及其全部变量？", name), func(ok bool) {
+		if !ok {
+			return
+		}
+		for i, env := range h.environments {
+			if env.Name == name {
+				h.environments = append(h.environments[:i], h.environments[i+1:]...)
+				break
+			}
+		}
+		h.currentEnv = ""
+		h.refreshEnvironments(envNone)
+	}, h.window)
+}
+
+// 读取Excel数据文件的工作表列表，当前工作表不存在时选择第一个
+func (h *HTTPTool) refreshSheets() {
+	path := strings.TrimSpace(h.csvPathEntry.Text)
+	if h.inputFormatSelect.Selected != engine.InputFormatXLSX || path == "" {
+		return
+	}
+	sheets, err := engine.SheetNames(path)
+	if err != nil {
+		h.appendLog(err.Error())
+		return
+	}
+	h.sheetEntry.SetOptions(sheets)
+	found := false
+	for _, sheet := range sheets {
+		found = found || sheet == h.sheetEntry.Text
+	}
+	if !found && len(sheets) > 0 {
+		h.sheetEntry.SetText(sheets[0])
+	}
+}
+
+// 所选的文件编码，自动检测时不写入配置
+func (h *HTTPTool) selectedEncoding() string {
+	if h.encodingSelect.Selected == engine.EncodingAuto {
+		return ""
+	}
+	return h.encodingSelect.Selected
+}
+
+// 检测 CSV / JSONL 数据文件的编码并显示在编码选择旁，所选编码与检测结果不同时提示
+func (h *HTTPTool) refreshEncoding() {
+	path := strings.TrimSpace(h.csvPathEntry.Text)
+	if h.inputFormatSelect == nil || path == "" || h.inputFormatSelect.Selected == engine.InputFormatXLSX {
+		return
+	}
+	detected, err := engine.DetectFileEncoding(path)
+	if err != nil {
+		h.encodingHint.SetText("")
+		return
+	}
+	switch h.encodingSelect.Selected {
+	case engine.EncodingAuto, detected:
+		h.encodingHint.SetText("检测结果: " + detected)
+	default:
+		h.encodingHint.SetText("检测结果: " + detected + "，与所选编码不同")
+	}
+}
+
+// 应用数据目录中的保险库文件
+func (h *HTTPTool) vaultPath() string {
+	return filepath.Join(h.getConfigDir(), engine.VaultFileName)
+}
+
+// 配置引用了密钥但保险库未解锁时先解锁，解锁后调用 retry，返回是否需要等待解锁
+func (h *HTTPTool) needsVault(retry func()) bool {
+	if h.vault != nil || !h.collectConfig().UsesSecrets() {
+		return false
+	}
+	h.unlockVault(retry)
+	return true
+}
+
+// 输入密码解锁保险库，保险库不存在时设置密码并创建，成功后调用 then
+func (h *HTTPTool) unlockVault(then func()) {
+	if h.vault != nil {
+		then()
+		return
+	}
+	path := h.vaultPath()
+	creating := !engine.VaultExists(path)
+	passEntry := widget.NewPasswordEntry()
+	items := []*widget.FormItem{widget.NewFormItem("密码", passEntry)}
+	confirmEntry := widget.NewPasswordEntry()
+	title := "🔐 解锁保险库"
+	if creating {
+		title = "🔐 创建保险库"
+		items = append(items, widget.NewFormItem("确认密码", confirmEntry))
+	}
+	form := dialog.NewForm(title, "确定", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		if creating && passEntry.Text != confirmEntry.Text {
+			dialog.ShowError(fmt.Errorf("两次输入的密码不一致"), h.window)
+			return
+		}
+		vault, err := engine.OpenVault(path, passEntry.Text)
+		if err == nil && creating {
+			err = vault.Save()
+		}
+		if err != nil {
+			dialog.ShowError(err, h.window)
+			return
+		}
+		h.vault = vault
+		h.appendLog(fmt.Sprintf("Vault unlocked, %d secrets", len(vault.Names())))
+		then()
+	}, h.window)
+	form.Resize(fyne.NewSize(400, 0))
+	form.Show()
+}
+
+// 管理保险库中的密钥：只显示名称，值不回显
+func (h *HTTPTool) showVault() {
+	h.unlockVault(func() {
+		names := h.vault.Names()
+		selected := -1
+		list := widget.NewList(
+			func() int { return len(names) },
+			func() fyne.CanvasObject { return widget.NewLabel("") },
+			func(id widget.ListItemID, item fyne.CanvasObject) {
+				item.(*widget.Label).SetText(engine.SecretRef(names[id]))
+			},
+		)
+		refresh := func() {
+			names = h.vault.Names()
+			selected = -1
+			list.UnselectAll()
+			list.Refresh()
+		}
+		list.OnSelected = func(id widget.ListItemID) {
+			selected = id
+		}
+		
+		addBtn := widget.NewButton("➕ 添加/更新", func() {
+			nameEntry := widget.NewEntry()
+			nameEntry.SetPlaceHolder("如 jd_cookie")
+			if selected >= 0 {
+				nameEntry.SetText(names[selected])
+			}
+			valueEntry := widget.NewPasswordEntry()
+			dialog.ShowForm("保存密钥", "保存", "取消", []*widget.FormItem{
+				widget.NewFormItem("名称", nameEntry),
+				widget.NewFormItem("值", valueEntry),
+			}, func(ok bool) {
+				if !ok {
+					return
+				}
+				err := h.vault.Set(strings.TrimSpace(nameEntry.Text), valueEntry.Text)
+				if err == nil {
+					err = h.vault.Save()
+				}
+				if err != nil {
+					dialog.ShowError(err, h.window)
+					return
+				}
+				refresh()
+			}, h.window)
+		})
+		deleteBtn := widget.NewButton("🗑 删除", func() {
+			if selected < 0 {
+				return
+			}
+			name := names[selected]
+			dialog.ShowConfirm("删除密钥", fmt.Sprintf("确定删除密钥 %s？引用它的配置将无法执行。", name), func(ok bool) {
+				if !ok {
+					return
+				}
+				h.vault.Delete(name)
+				if err := h.vault.Save(); err != nil {
+					dialog.ShowError(err, h.window)
+					return
+				}
+				refresh()
+			}, h.window)
+		})
+		
+		var vaultDialog dialog.Dialog
+		lockBtn := widget.NewButton("🔒 锁定", func() {
+			h.vault = nil
+			h.appendLog("Vault locked")
+			vaultDialog.Hide()
+		})
+		hint := widget.NewLabel("密钥以密码加密保存在 " + h.vaultPath() + "\n在Cookie、请求头、请求体、地址和环境变量中用 ${secret:名称} 引用")
+		hint.Wrapping = fyne.TextWrapWord
+		content := container.NewBorder(
+			hint,
+			container.NewHBox(addBtn, deleteBtn, lockBtn),
+			nil, nil,
+			list,
+		)
+		vaultDialog = dialog.NewCustom("🔐 保险库", "关闭", content, h.window)
+		vaultDialog.Resize(fyne.NewSize(560, 460))
+		vaultDialog.Show()
+	})
+}
+
+// 按逗号、分号或换行拆分列表，去掉空白项
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '，'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 解析流量曲线，格式错误时返回空，开始执行前由 validateInputs 报告错误
+func (h *HTTPTool) parseProfile() []engine.Stage {
+	stages, err := engine.ParseStages(h.profileEntry.Text)
+	if err != nil {
+		return nil
+	}
+	return stages
+}
+
 func (h *HTTPTool) parseIntOrDefault(s string, defaultValue int) int {
 	if val, err := strconv.Atoi(s); err == nil {
 		return val
@@ -1291,7 +2080,7 @@ func (h *HTTPTool) parseIntOrDefault(s string, defaultValue int) int {
 // 创建参数映射行
 func (h *HTTPTool) createParamMappingRow() *ParamMappingRow {
 	csvColumnEntry := widget.NewEntry()
-	csvColumnEntry.SetPlaceHolder("列名或索引(如: 0, 1, name)。列表类型用逗号/分号分隔")
+	csvColumnEntry.SetPlaceHolder("列名(不区分大小写)或索引(如: 0, 1, userId)，JSONL 为JSON路径(如: $.order.id)。列表类型用逗号/分号分隔")
 	
 	paramNameEntry := widget.NewEntry()
 	if h.config.ParamMode == "object" {
@@ -1301,7 +2090,7 @@ func (h *HTTPTool) createParamMappingRow() *ParamMappingRow {
 	}
 	
 	paramTypeSelect := widget.NewSelect(
-		[]string{"string", "int", "float", "bool", "string[]", "int[]"},
+		[]string{"string", "int", "float", "bool", "string[]", "int[]", "json"},
 		nil,
 	)
 	paramTypeSelect.SetSelected("string")
@@ -1376,7 +2165,7 @@ func (h *HTTPTool) refreshParamMappingContainer() {
 	var headerContainer *fyne.Container
 	if h.config.ParamMode == "array" {
 		headerContainer = container.NewGridWithColumns(6,
-			widget.NewLabel("CSV列"),
+			widget.NewLabel("CSV列/JSON路径"),
 			widget.NewLabel("数组索引"),
 			widget.NewLabel("参数描述"),
 			widget.NewLabel("类型"),
@@ -1385,7 +2174,7 @@ func (h *HTTPTool) refreshParamMappingContainer() {
 		)
 	} else {
 		headerContainer = container.NewGridWithColumns(5,
-			widget.NewLabel("CSV列"),
+			widget.NewLabel("CSV列/JSON路径"),
 			widget.NewLabel("参数名"),
 			widget.NewLabel("类型"),
 			widget.NewLabel("默认值"),
@@ -1435,7 +2224,9 @@ func (h *HTTPTool) refreshParamMappingContainer() {
 	
 	// 如果没有映射行，显示提示信息
 	if len(h.paramMappingList) == 0 {
-		if h.config.ParamMode == "array" {
+		if h.config.ParamMode == engine.ParamModePassthrough {
+			h.paramMappingContainer.Add(widget.NewLabel("透传模式：JSONL 的每行原样作为参数（${jsonParam}），无需配置参数映射"))
+		} else if h.config.ParamMode == "array" {
 			h.paramMappingContainer.Add(widget.NewLabel("暂无参数映射配置，数组模式将按索引顺序生成参数列表"))
 		} else {
 			h.paramMappingContainer.Add(widget.NewLabel("暂无参数映射配置，对象模式将生成键值对参数"))
@@ -1456,11 +2247,11 @@ func (h *HTTPTool) refreshParamMappingContainer() {
 }
 
 // 获取参数映射配置
-func (h *HTTPTool) getParamMappings() []ParamMapping {
-	var mappings []ParamMapping
+func (h *HTTPTool) getParamMappings() []engine.ParamMapping {
+	var mappings []engine.ParamMapping
 	for _, row := range h.paramMappingList {
 		if strings.TrimSpace(row.CSVColumnEntry.Text) != "" {
-			mapping := ParamMapping{
+			mapping := engine.ParamMapping{
 				CSVColumn:    strings.TrimSpace(row.CSVColumnEntry.Text),
 				ParamName:    strings.TrimSpace(row.ParamNameEntry.Text),
 				ParamType:    row.ParamTypeSelect.Selected,
@@ -1481,7 +2272,7 @@ func (h *HTTPTool) getParamMappings() []ParamMapping {
 }
 
 // 设置参数映射配置
-func (h *HTTPTool) setParamMappings(mappings []ParamMapping) {
+func (h *HTTPTool) setParamMappings(mappings []engine.ParamMapping) {
 	h.paramMappingList = nil
 	for _, mapping := range mappings {
 		row := h.createParamMappingRow()
@@ -1496,3 +2287,220 @@ func (h *HTTPTool) setParamMappings(mappings []ParamMapping) {
 	}
 	h.refreshParamMappingContainer()
 }
+
+// 创建请求头行
+func (h *HTTPTool) createHeaderRow(header engine.Header) *HeaderRow {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("名称(如: Authorization)")
+	nameEntry.SetText(header.Name)
+
+	valueEntry := widget.NewEntry()
+	valueEntry.SetPlaceHolder("值(如: Bearer ${env:TOKEN})")
+	valueEntry.SetText(header.Value)
+
+	row := &HeaderRow{
+		NameEntry:  nameEntry,
+		ValueEntry: valueEntry,
+	}
+
+	deleteButton := widget.NewButton("🗑", func() {
+		h.removeHeaderRow(row)
+	})
+	deleteButton.Importance = widget.LowImportance
+	row.DeleteButton = deleteButton
+
+	row.Container = container.NewBorder(nil, nil, nil, deleteButton,
+		container.NewGridWithColumns(2, nameEntry, valueEntry))
+	return row
+}
+
+// 移除请求头行
+func (h *HTTPTool) removeHeaderRow(targetRow *HeaderRow) {
+	for i, row := range h.headerList {
+		if row == targetRow {
+			h.headerList = append(h.headerList[:i], h.headerList[i+1:]...)
+			break
+		}
+	}
+	h.refreshHeaderContainer()
+}
+
+// 刷新请求头容器
+func (h *HTTPTool) refreshHeaderContainer() {
+	h.headerContainer.RemoveAll()
+
+	for _, row := range h.headerList {
+		h.headerContainer.Add(row.Container)
+	}
+	if len(h.headerList) == 0 {
+		h.headerContainer.Add(widget.NewLabel("暂无自定义请求头"))
+	}
+
+	addButton := widget.NewButton("➕ 添加请求头", func() {
+		h.headerList = append(h.headerList, h.createHeaderRow(engine.Header{}))
+		h.refreshHeaderContainer()
+	})
+	addButton.Importance = widget.MediumImportance
+
+	// 预设只补充或覆盖同名请求头，不影响其他已填写的请求头
+	presetButton := widget.NewButton("JSF 网关预设", func() {
+		h.setHeaders(mergeHeaders(h.getHeaders(), engine.JSFHeaders()))
+	})
+
+	h.headerContainer.Add(container.NewHBox(addButton, presetButton))
+	h.headerContainer.Refresh()
+}
+
+// 获取请求头配置，忽略名称为空的行
+func (h *HTTPTool) getHeaders() []engine.Header {
+	var headers []engine.Header
+	for _, row := range h.headerList {
+		if name := strings.TrimSpace(row.NameEntry.Text); name != "" {
+			headers = append(headers, engine.Header{Name: name, Value: row.ValueEntry.Text})
+		}
+	}
+	return headers
+}
+
+// 设置请求头配置
+func (h *HTTPTool) setHeaders(headers []engine.Header) {
+	h.headerList = nil
+	for _, header := range headers {
+		h.headerList = append(h.headerList, h.createHeaderRow(header))
+	}
+	h.refreshHeaderContainer()
+}
+
+// 合并请求头，同名（不区分大小写）时以 overrides 为准
+func mergeHeaders(headers, overrides []engine.Header) []engine.Header {
+	merged := append([]engine.Header{}, headers...)
+	for _, override := range overrides {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, override.Name) {
+				merged[i].Value = override.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
+
+// 创建响应断言行
+func (h *HTTPTool) createAssertionRow(assertion engine.Assertion) *AssertionRow {
+	typeSelect := widget.NewSelect(engine.AssertTypes, nil)
+	typeSelect.SetSelected(assertion.Type)
+
+	fieldEntry := widget.NewEntry()
+	fieldEntry.SetPlaceHolder("路径/请求头名")
+	fieldEntry.SetText(assertion.Field)
+
+	opSelect := widget.NewSelect(engine.AssertOps, nil)
+	opSelect.SetSelected(assertion.Op)
+
+	valueEntry := widget.NewEntry()
+	valueEntry.SetPlaceHolder("值/正则/范围")
+	valueEntry.SetText(assertion.Value)
+
+	outcomeSelect := widget.NewSelect(engine.Outcomes, nil)
+	outcomeSelect.SetSelected(assertion.Outcome)
+
+	row := &AssertionRow{
+		TypeSelect:    typeSelect,
+		FieldEntry:    fieldEntry,
+		OpSelect:      opSelect,
+		ValueEntry:    valueEntry,
+		OutcomeSelect: outcomeSelect,
+		original:      assertion,
+	}
+
+	deleteButton := widget.NewButton("🗑", func() {
+		h.removeAssertionRow(row)
+	})
+	deleteButton.Importance = widget.LowImportance
+	row.DeleteButton = deleteButton
+
+	row.Container = container.NewBorder(nil, nil, nil, deleteButton,
+		container.NewGridWithColumns(5, typeSelect, fieldEntry, opSelect, valueEntry, outcomeSelect))
+	return row
+}
+
+// 移除响应断言行
+func (h *HTTPTool) removeAssertionRow(targetRow *AssertionRow) {
+	for i, row := range h.assertionList {
+		if row == targetRow {
+			h.assertionList = append(h.assertionList[:i], h.assertionList[i+1:]...)
+			break
+		}
+	}
+	h.refreshAssertionContainer()
+}
+
+// 刷新响应断言容器
+func (h *HTTPTool) refreshAssertionContainer() {
+	h.assertionContainer.RemoveAll()
+
+	h.assertionContainer.Add(container.NewBorder(nil, nil, nil, widget.NewLabel("操作"),
+		container.NewGridWithColumns(5,
+			widget.NewLabel("类型"),
+			widget.NewLabel("字段"),
+			widget.NewLabel("比较"),
+			widget.NewLabel("值"),
+			widget.NewLabel("结果"),
+		)))
+	for _, row := range h.assertionList {
+		h.assertionContainer.Add(row.Container)
+	}
+	if len(h.assertionList) == 0 {
+		h.assertionContainer.Add(widget.NewLabel("暂无断言规则，执行时使用默认规则"))
+	}
+
+	addButton := widget.NewButton("➕ 添加断言", func() {
+		h.assertionList = append(h.assertionList, h.createAssertionRow(engine.Assertion{
+			Type: engine.AssertJSONPath, Field: "$.code", Op: "!=", Value: "0", Outcome: engine.OutcomeFail,
+		}))
+		h.refreshAssertionContainer()
+	})
+	addButton.Importance = widget.MediumImportance
+
+	resetButton := widget.NewButton("恢复默认规则", func() {
+		h.setAssertions(engine.DefaultAssertions())
+	})
+
+	h.assertionContainer.Add(container.NewHBox(addButton, resetButton))
+	h.assertionContainer.Refresh()
+}
+
+// 获取响应断言配置
+func (h *HTTPTool) getAssertions() []engine.Assertion {
+	var assertions []engine.Assertion
+	for _, row := range h.assertionList {
+		assertion := engine.Assertion{
+			Name:    row.original.Name,
+			Type:    row.TypeSelect.Selected,
+			Field:   strings.TrimSpace(row.FieldEntry.Text),
+			Op:      row.OpSelect.Selected,
+			Value:   row.ValueEntry.Text,
+			Outcome: row.OutcomeSelect.Selected,
+		}
+		// 规则被修改后原说明不再准确，由规则内容生成
+		if assertion != row.original {
+			assertion.Name = ""
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions
+}
+
+// 设置响应断言配置
+func (h *HTTPTool) setAssertions(assertions []engine.Assertion) {
+	h.assertionList = nil
+	for _, assertion := range assertions {
+		h.assertionList = append(h.assertionList, h.createAssertionRow(assertion))
+	}
+	h.refreshAssertionContainer()
+}